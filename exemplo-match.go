@@ -1,57 +1,55 @@
 package main
 
 import (
-	"sync"
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
 )
 
 // Integração com seu Service existente
 type Service struct {
 	// Seus campos existentes...
 	
-	// Novo matcher ultra rápido
-	matcher     *UltraFastMatcher
-	matcherOnce sync.Once
-	patterns    []string // Cache dos patterns para lazy loading
+	// Matcher atual. Trocas são atômicas: quem já fez Load termina o match
+	// com a versão antiga, e as próximas chamadas veem a nova.
+	matcher atomic.Pointer[UltraFastMatcher]
+}
+
+func (s *Service) matcherOptions() *MatcherOptions {
+	return &MatcherOptions{
+		EnableCache:        true,
+		CaseSensitive:     true,
+		MatchBasenameOnly: true,
+	}
 }
 
 // Substituição da função original
-func (s *Service) shouldIncludeFile(relativePath string, patterns []string) (bool, PatternType) {
-	// Lazy initialization do matcher
-	s.matcherOnce.Do(func() {
-		opts := &MatcherOptions{
-			EnableCache:        true,
-			CaseSensitive:     true,
-			MatchBasenameOnly: true,
-		}
-		
-		matcher, err := NewUltraFastMatcher(patterns, opts)
+func (s *Service) shouldIncludeFile(relativePath string, patterns []TypedPattern) (bool, string) {
+	m := s.matcher.Load()
+	
+	// Lazy initialization do matcher; se UpdatePatterns ou o watcher
+	// chegarem antes, a versão deles é mantida
+	if m == nil {
+		built, err := NewUltraFastMatcher(patterns, s.matcherOptions())
 		if err != nil {
 			// Fallback para implementação original se houver erro
-			s.matcher = nil
-			return
-		}
-		s.matcher = matcher
-		s.patterns = patterns
-	})
-	
-	// Se o matcher foi inicializado com sucesso, usa ele
-	if s.matcher != nil {
-		matched := s.matcher.Match(relativePath)
-		if matched {
-			return true, PatternTypeMatch // ou o tipo que você usar
+			return s.shouldIncludeFileFallback(relativePath, patterns)
 		}
-		return false, PatternTypeNone
+		s.matcher.CompareAndSwap(nil, built)
+		m = s.matcher.Load()
 	}
 	
-	// Fallback para implementação original
-	return s.shouldIncludeFileFallback(relativePath, patterns)
+	result := m.Match(relativePath)
+	return result.Matched, result.Type
 }
 
 // Versão otimizada da função matchPattern original
 func (s *Service) matchPattern(path, pattern string) bool {
-	// Se temos matcher inicializado, cria um temporário para este pattern
-	if matcher, err := NewUltraFastMatcher([]string{pattern}, nil); err == nil {
-		return matcher.Match(path)
+	// Cria um matcher temporário só com este pattern
+	if matcher, err := NewUltraFastMatcher([]TypedPattern{{Pattern: pattern}}, nil); err == nil {
+		return matcher.Match(path).Matched
 	}
 	
 	// Fallback para implementação original
@@ -59,9 +57,9 @@ func (s *Service) matchPattern(path, pattern string) bool {
 }
 
 // Fallbacks para compatibilidade (suas funções originais)
-func (s *Service) shouldIncludeFileFallback(relativePath string, patterns []string) (bool, PatternType) {
+func (s *Service) shouldIncludeFileFallback(relativePath string, patterns []TypedPattern) (bool, string) {
 	// Sua implementação original aqui...
-	return false, PatternTypeNone
+	return false, ""
 }
 
 func (s *Service) matchPatternFallback(path, pattern string) bool {
@@ -70,36 +68,48 @@ func (s *Service) matchPatternFallback(path, pattern string) bool {
 }
 
 // Método utilitário para recarregar patterns
-func (s *Service) UpdatePatterns(newPatterns []string) error {
-	opts := &MatcherOptions{
-		EnableCache:        true,
-		CaseSensitive:     true,
-		MatchBasenameOnly: true,
+func (s *Service) UpdatePatterns(newPatterns []TypedPattern) error {
+	matcher, err := NewUltraFastMatcher(newPatterns, s.matcherOptions())
+	if err != nil {
+		return err
 	}
 	
-	matcher, err := NewUltraFastMatcher(newPatterns, opts)
+	s.matcher.Store(matcher)
+	return nil
+}
+
+// WatchPatterns carrega o arquivo de patterns e o recarrega sempre que ele
+// (ou um %include) mudar, até ctx ser cancelado. Uma versão com erro é
+// ignorada e a anterior continua valendo.
+func (s *Service) WatchPatterns(ctx context.Context, path string, interval time.Duration) error {
+	matcher, err := WatchPatternFile(ctx, path, interval, s.matcherOptions(), func(m *UltraFastMatcher, err error) {
+		if err != nil {
+			log.Printf("patterns não recarregados: %v", err)
+			return
+		}
+		s.matcher.Store(m)
+	})
 	if err != nil {
 		return err
 	}
 	
-	s.matcher = matcher
-	s.patterns = newPatterns
+	s.matcher.Store(matcher)
 	return nil
 }
 
 // Método para obter estatísticas
 func (s *Service) GetMatcherStats() *MatcherStats {
-	if s.matcher != nil {
-		stats := s.matcher.Stats()
+	if m := s.matcher.Load(); m != nil {
+		stats := m.Stats()
 		return &stats
 	}
 	return nil
 }
 
 // Exemplo de uso específico para seu cenário
-func (s *Service) ProcessFiles(filePaths []string, patterns []string) []string {
+func (s *Service) ProcessFiles(filePaths []string, patterns []TypedPattern) []string {
 	// Inicializa matcher se necessário
-	if s.matcher == nil {
+	if s.matcher.Load() == nil {
 		if err := s.UpdatePatterns(patterns); err != nil {
 			// Handle error - talvez log e use fallback
 			return s.processFilesFallback(filePaths, patterns)
@@ -108,11 +118,12 @@ func (s *Service) ProcessFiles(filePaths []string, patterns []string) []string {
 	
 	var matchedFiles []string
 	
-	// Processa em batch para máxima performance
-	results := s.matcher.MatchBatch(filePaths)
+	// Processa em batch para máxima performance; o lote inteiro usa a mesma
+	// versão dos patterns mesmo que haja um reload no meio
+	results := s.matcher.Load().MatchBatch(filePaths)
 	
-	for i, matched := range results {
-		if matched {
+	for i, result := range results {
+		if result.Matched {
 			matchedFiles = append(matchedFiles, filePaths[i])
 		}
 	}
@@ -120,7 +131,7 @@ func (s *Service) ProcessFiles(filePaths []string, patterns []string) []string {
 	return matchedFiles
 }
 
-func (s *Service) processFilesFallback(filePaths []string, patterns []string) []string {
+func (s *Service) processFilesFallback(filePaths []string, patterns []TypedPattern) []string {
 	// Implementação fallback usando seu código original
 	var matchedFiles []string
 	// ... sua lógica original
@@ -130,10 +141,11 @@ func (s *Service) processFilesFallback(filePaths []string, patterns []string) []
 // === EXEMPLO DE BENCHMARK ===
 /*
 func BenchmarkMatchPattern(b *testing.B) {
-	patterns := []string{
-		"*.go", "*.js", "*.test.go", 
-		"node_modules/*", "vendor/*", 
-		"**/*.min.js", "**/.git/**",
+	patterns := []TypedPattern{
+		{Pattern: "*.go", Type: "Code"}, {Pattern: "*.js", Type: "Code"},
+		{Pattern: "*.test.go", Type: "Test"},
+		{Pattern: "!node_modules/*"}, {Pattern: "!vendor/*"},
+		{Pattern: "!*.min.js"}, {Pattern: "!.git/*"},
 	}
 	
 	testPaths := []string{
@@ -148,7 +160,7 @@ func BenchmarkMatchPattern(b *testing.B) {
 		for i := 0; i < b.N; i++ {
 			for _, path := range testPaths {
 				for _, pattern := range patterns {
-					service.matchPatternFallback(path, pattern)
+					service.matchPatternFallback(path, pattern.Pattern)
 				}
 			}
 		}
@@ -176,7 +188,7 @@ func BenchmarkMatchPattern(b *testing.B) {
 // === UTILITÁRIOS ADICIONAIS ===
 
 // Para debug - mostra como cada pattern foi categorizado
-func AnalyzePatternOptimization(patterns []string) {
+func AnalyzePatternOptimization(patterns []TypedPattern) {
 	matcher, err := NewUltraFastMatcher(patterns, nil)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
@@ -194,7 +206,7 @@ func AnalyzePatternOptimization(patterns []string) {
 	fmt.Printf("  • Exact basenames: %d\n", stats.ExactBasenames) 
 	fmt.Printf("  • Extensions: %d\n", stats.Extensions)
 	
-	fmt.Printf("\n⚡ Very Fast (O(n) but n small):\n")
+	fmt.Printf("\n⚡ Very Fast (trie, O(path depth)):\n")
 	fmt.Printf("  • Prefixes: %d\n", stats.Prefixes)
 	fmt.Printf("  • Suffixes: %d\n", stats.Suffixes)
 	
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// PathResult associa o resultado ao path de entrada
+type PathResult struct {
+	Path   string
+	Result MatchResult
+}
+
+// batchChunk é quantos índices cada worker reserva por vez em
+// MatchBatchParallel; o contexto é verificado a cada chunk
+const batchChunk = 1024
+
+// MatchBatchParallel é o MatchBatch com um pool de Workers goroutines. Os
+// resultados ficam na mesma posição dos paths. Se o contexto for cancelado,
+// retorna ctx.Err() e resultados parciais.
+func (m *UltraFastMatcher) MatchBatchParallel(ctx context.Context, paths []string) ([]MatchResult, error) {
+	results := make([]MatchResult, len(paths))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+
+	for w := 0; w < m.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				start := int(next.Add(batchChunk)) - batchChunk
+				if start >= len(paths) {
+					return
+				}
+				end := min(start+batchChunk, len(paths))
+				for i := start; i < end; i++ {
+					results[i] = m.Match(paths[i])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results, ctx.Err()
+}
+
+// MatchStream classifica os paths recebidos em paths com Workers goroutines.
+// O canal de saída é fechado quando paths é fechado e tudo foi emitido, ou
+// logo após o cancelamento do contexto (nesse caso resultados podem faltar).
+// Com PreserveOrder a saída segue a ordem de entrada.
+func (m *UltraFastMatcher) MatchStream(ctx context.Context, paths <-chan string) <-chan PathResult {
+	if m.preserveOrder {
+		return m.matchStreamOrdered(ctx, paths)
+	}
+
+	out := make(chan PathResult, m.workers)
+	var wg sync.WaitGroup
+	for w := 0; w < m.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				path, ok := receive(ctx, paths)
+				if !ok {
+					return
+				}
+				if !send(ctx, out, PathResult{path, m.Match(path)}) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// streamJob é um path com o canal onde o worker entrega o resultado
+type streamJob struct {
+	path   string
+	result chan PathResult
+}
+
+// matchStreamOrdered despacha os jobs para os workers e, em paralelo, emite os
+// resultados na ordem de despacho; o buffer de pending limita quantos
+// resultados ficam esperando por um path mais lento
+func (m *UltraFastMatcher) matchStreamOrdered(ctx context.Context, paths <-chan string) <-chan PathResult {
+	out := make(chan PathResult, m.workers)
+	jobs := make(chan streamJob, m.workers)
+	pending := make(chan chan PathResult, 4*m.workers)
+
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for {
+			path, ok := receive(ctx, paths)
+			if !ok {
+				return
+			}
+			job := streamJob{path, make(chan PathResult, 1)}
+			if !send(ctx, pending, job.result) || !send(ctx, jobs, job) {
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < m.workers; w++ {
+		go func() {
+			for job := range jobs {
+				job.result <- PathResult{job.path, m.Match(job.path)}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(out)
+		for result := range pending {
+			select {
+			case r := <-result:
+				if !send(ctx, out, r) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// receive lê do canal até ele fechar ou o contexto ser cancelado
+func receive[T any](ctx context.Context, ch <-chan T) (T, bool) {
+	var zero T
+	if ctx.Err() != nil {
+		return zero, false
+	}
+	select {
+	case v, ok := <-ch:
+		return v, ok
+	case <-ctx.Done():
+		return zero, false
+	}
+}
+
+// send escreve no canal, desistindo se o contexto for cancelado
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultCacheSize   = 64 * 1024
+	defaultCacheShards = 16
+)
+
+// lruCache é um cache LRU limitado e dividido em shards, cada um com seu
+// próprio lock, para não serializar os goroutines que chamam Match
+type lruCache struct {
+	seed   maphash.Seed
+	shards []cacheShard
+	mask   uint64
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type cacheShard struct {
+	mu       sync.Mutex
+	items    map[string]*list.Element
+	order    *list.List // frente = usado mais recentemente
+	capacity int
+}
+
+type cacheEntry struct {
+	key    string
+	result MatchResult
+}
+
+// newLRUCache cria um cache com no máximo size entradas. O número de shards é
+// arredondado para uma potência de 2 sem passar de size, e as capacidades dos
+// shards somam exatamente size.
+func newLRUCache(size, shards int) *lruCache {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	if shards <= 0 {
+		shards = defaultCacheShards
+	}
+	n := 1
+	for n < shards && n*2 <= size {
+		n <<= 1
+	}
+
+	c := &lruCache{
+		seed:   maphash.MakeSeed(),
+		shards: make([]cacheShard, n),
+		mask:   uint64(n - 1),
+	}
+	for i := range c.shards {
+		capacity := size / n
+		if i < size%n {
+			capacity++
+		}
+		c.shards[i] = cacheShard{
+			items:    make(map[string]*list.Element),
+			order:    list.New(),
+			capacity: capacity,
+		}
+	}
+	return c
+}
+
+func (c *lruCache) shard(key string) *cacheShard {
+	return &c.shards[maphash.String(c.seed, key)&c.mask]
+}
+
+func (c *lruCache) get(key string) (MatchResult, bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	var result MatchResult
+	el, ok := s.items[key]
+	if ok {
+		s.order.MoveToFront(el)
+		result = el.Value.(*cacheEntry).result
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		c.misses.Add(1)
+		return MatchResult{}, false
+	}
+	c.hits.Add(1)
+	return result, true
+}
+
+func (c *lruCache) put(key string, result MatchResult) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Uma entrada já publicada nunca é alterada: dois misses da mesma chave
+	// trocam o elemento inteiro
+	if el, ok := s.items[key]; ok {
+		s.order.Remove(el)
+		s.items[key] = s.order.PushFront(&cacheEntry{key, result})
+		return
+	}
+	s.items[key] = s.order.PushFront(&cacheEntry{key, result})
+
+	if s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*cacheEntry).key)
+		c.evictions.Add(1)
+	}
+}
+
+// clear descarta todas as entradas, sem zerar os contadores
+func (c *lruCache) clear() {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		s.items = make(map[string]*list.Element)
+		s.order.Init()
+		s.mu.Unlock()
+	}
+}
+
+func (c *lruCache) len() int {
+	total := 0
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		total += s.order.Len()
+		s.mu.Unlock()
+	}
+	return total
+}
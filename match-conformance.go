@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing/fstest"
+)
+
+// ConformanceCase é um pattern, um path e se ele deve casar
+type ConformanceCase struct {
+	Pattern string
+	Path    string
+	Match   bool
+}
+
+// conformanceCases documenta a semântica dos globs: "*" e "?" ficam num
+// segmento, "**" casa com zero ou mais diretórios e os tiers rápidos mantêm
+// os atalhos "dir/*" e "*/nome".
+var conformanceCases = []ConformanceCase{
+	// "*" e "?" não atravessam "/"
+	{"cmd/*.go", "cmd/main.go", true},
+	{"cmd/*.go", "cmd/sub/main.go", false},
+	{"a*b", "axxb", true},
+	{"a*b", "a/b", false},
+	{"a?b", "a/b", false},
+	{"*_test.go", "pkg/x_test.go", true}, // sem "/" o basename também vale
+
+	// **/x: qualquer profundidade, inclusive a raiz
+	{"**/*.go", "main.go", true},
+	{"**/*.go", "cmd/x/main.go", true},
+	{"**/cmd", "cmd", true},
+	{"**/cmd", "a/b/cmd", true},
+	{"**/cmd", "cmdx", false},
+	{"**/cmd", "a/cmd/main.go", false},
+
+	// x/**: o próprio x e tudo abaixo dele
+	{"docs/**", "docs", true},
+	{"docs/**", "docs/a.md", true},
+	{"docs/**", "docs/a/b.md", true},
+	{"docs/**", "a/docs", false},
+	{"docs/**", "docsx/a.md", false},
+
+	// a/**/b: zero ou mais diretórios no meio
+	{"src/**/test", "src/test", true},
+	{"src/**/test", "src/a/b/test", true},
+	{"src/**/test", "src/atest", false},
+	{"src/**/test", "x/src/test", false},
+	{"a/**/b/**/c", "a/b/c", true},
+	{"a/**/b/**/c", "a/x/b/y/z/c", true},
+	{"a/**/b/**/c", "a/bc", false},
+
+	// **x**: o segmento x em qualquer profundidade, com ou sem conteúdo
+	{"**vendor**", "vendor", true},
+	{"**vendor**", "vendor/lib.go", true},
+	{"**vendor**", "a/vendor", true},
+	{"**vendor**", "a/vendor/lib.go", true},
+	{"**vendor**", "a/vendors/lib.go", false},
+	{"**/node_modules/**", "node_modules/a.js", true},
+	{"**/node_modules/**", "web/node_modules/b/c.js", true},
+
+	// "**" sozinho casa com tudo; colado a outros caracteres atravessa "/"
+	{"**", "a/b/c", true},
+	{"src/**.go", "src/a/b.go", true},
+
+	// Com chaves
+	{"**/*.{go,py}", "a/b.py", true},
+	{"{src,lib}/**/*.go", "lib/a.go", true},
+	{"{src,lib}/**/*.go", "lib/a/b.go", true},
+	{"{src,lib}/*.go", "lib/a/b.go", false},
+
+	// Tiers rápidos: "dir/*" é todo o conteúdo de dir e "*/nome" casa em
+	// qualquer profundidade, mas não na raiz
+	{"src/*", "src/a/b.go", true},
+	{"src/*", "src", false},
+	{"*/test", "a/b/test", true},
+	{"*/test", "test", false},
+}
+
+// nestedTree e nestedCases verificam o walk com arquivos aninhados: a raiz não
+// menciona services/, mas o walk precisa entrar nele para achar o arquivo de
+// services/billing; vendor/ é pulado porque uma negação o cobre. Pattern diz
+// de onde vem a regra e Match se o walk deve listar o path.
+var (
+	nestedRoot = []TypedPattern{{Pattern: "src/*", Type: "Code"}, {Pattern: "!vendor/**"}}
+	nestedTree = fstest.MapFS{
+		"src/main.go":                            {Data: []byte("package main")},
+		"services/billing/" + nestedPatternsFile: {Data: []byte("[Billing]\n*.go\n")},
+		"services/billing/x.go":                  {Data: []byte("package billing")},
+		"services/other/y.go":                    {Data: []byte("package other")},
+		"vendor/v.go":                            {Data: []byte("package v")},
+	}
+	nestedCases = []ConformanceCase{
+		{"src/*", "src/main.go", true},
+		{"services/billing/" + nestedPatternsFile, "services/billing/x.go", true},
+		{"(nenhuma)", "services/other/y.go", false},
+		{"!vendor/**", "vendor/v.go", false},
+	}
+)
+
+// CheckConformance roda a tabela com as opções padrão, compilando cada
+// pattern e também recarregando-o de um snapshot, e depois os casos de
+// arquivos aninhados. Retorna os casos que falharam.
+func CheckConformance() ([]ConformanceCase, error) {
+	var failures []ConformanceCase
+	for _, c := range conformanceCases {
+		patterns := []TypedPattern{{Pattern: c.Pattern, Type: "X"}}
+		m, err := NewUltraFastMatcher(patterns, nil)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := m.WriteSnapshot(&buf); err != nil {
+			return nil, err
+		}
+		loaded, err := ReadSnapshot(&buf, nil)
+		if err != nil {
+			return nil, err
+		}
+		if m.Match(c.Path).Matched != c.Match || loaded.Match(c.Path).Matched != c.Match {
+			failures = append(failures, c)
+		}
+	}
+
+	base, err := NewUltraFastMatcher(nestedRoot, nil)
+	if err != nil {
+		return nil, err
+	}
+	walked := make(map[string]bool)
+	err = NewHierarchicalMatcher(nestedTree, base, nil).WalkMatches(".", func(path string, _ MatchResult) error {
+		walked[path] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range nestedCases {
+		if walked[c.Path] != c.Match {
+			failures = append(failures, c)
+		}
+	}
+	return failures, nil
+}
+
+// runConformance imprime os casos que falharam; usado pelo comando
+// "conformance"
+func runConformance(w io.Writer) bool {
+	failures, err := CheckConformance()
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return false
+	}
+	for _, c := range failures {
+		verb := "não deveria casar"
+		if c.Match {
+			verb = "deveria casar"
+		}
+		fmt.Fprintf(w, "❌ %q %s com %q\n", c.Pattern, verb, c.Path)
+	}
+
+	if len(failures) > 0 {
+		fmt.Fprintf(w, "❌ %d de %d casos falharam\n", len(failures), len(conformanceCases)+len(nestedCases))
+		return false
+	}
+	fmt.Fprintf(w, "✅ %d casos ok\n", len(conformanceCases)+len(nestedCases))
+	return true
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// contentHeadSize é quanto do início do arquivo MatchFile lê; é o mesmo
+// limite que o git usa para decidir se um arquivo é binário
+const contentHeadSize = 8000
+
+// generatedMarker segue a convenção do Go, aceitando outros comentários
+var generatedMarker = regexp.MustCompile(`(?m)^\W*Code generated .* DO NOT EDIT\.?\s*$`)
+
+// contentPredicate reúne os predicados de conteúdo e atributos de uma regra
+type contentPredicate struct {
+	shebang   string
+	magic     string
+	firstLine *regexp.Regexp
+
+	minSize    int64
+	maxSize    int64
+	binary     bool
+	executable bool
+	generated  bool
+}
+
+// contentRule é uma regra de conteúdo: o glob testa o path, o predicado o
+// arquivo
+type contentRule struct {
+	path typedGlob
+	pred *contentPredicate
+}
+
+func hasContentPredicates(tp TypedPattern) bool {
+	return tp.Shebang != "" || tp.Magic != "" || tp.FirstLine != "" ||
+		tp.MinSize > 0 || tp.MaxSize > 0 || tp.Binary || tp.Executable || tp.Generated
+}
+
+// addContentRule compila os predicados de tp e registra a regra
+func (m *UltraFastMatcher) addContentRule(tg typedGlob, tp TypedPattern) error {
+	pred := &contentPredicate{
+		shebang:    tp.Shebang,
+		magic:      tp.Magic,
+		minSize:    tp.MinSize,
+		maxSize:    tp.MaxSize,
+		binary:     tp.Binary,
+		executable: tp.Executable,
+		generated:  tp.Generated,
+	}
+	if tp.FirstLine != "" {
+		re, err := regexp.Compile(tp.FirstLine)
+		if err != nil {
+			return fmt.Errorf("invalid first-line regexp %q: %w", tp.FirstLine, err)
+		}
+		pred.firstLine = re
+	}
+	m.contentRules = append(m.contentRules, contentRule{tg, pred})
+	m.rules[tg.rule].content = pred
+	m.rules[tg.rule].tier = tierContent
+	return nil
+}
+
+// matches verifica os predicados; os de stat vêm antes e evitam a leitura
+// quando já falham
+func (p *contentPredicate) matches(f *fileProbe) (bool, error) {
+	if p.minSize > 0 || p.maxSize > 0 || p.executable {
+		info, err := f.stat()
+		if err != nil {
+			return false, err
+		}
+		if p.minSize > 0 && info.Size() < p.minSize || p.maxSize > 0 && info.Size() > p.maxSize {
+			return false, nil
+		}
+		if p.executable && info.Mode()&0o111 == 0 {
+			return false, nil
+		}
+	}
+	if p.magic == "" && p.shebang == "" && p.firstLine == nil && !p.binary && !p.generated {
+		return true, nil
+	}
+
+	head, err := f.start()
+	if err != nil {
+		return false, err
+	}
+	return p.matchesHead(head), nil
+}
+
+func (p *contentPredicate) matchesHead(head []byte) bool {
+	if p.magic != "" && !bytes.HasPrefix(head, []byte(p.magic)) {
+		return false
+	}
+	if p.binary && bytes.IndexByte(head, 0) < 0 {
+		return false
+	}
+	if p.generated && !generatedMarker.Match(head) {
+		return false
+	}
+	line := firstLine(head)
+	if p.shebang != "" && !shebangMatches(line, p.shebang) {
+		return false
+	}
+	if p.firstLine != nil && !p.firstLine.Match(line) {
+		return false
+	}
+	return true
+}
+
+// fileProbe faz o stat e a leitura do início do arquivo no máximo uma vez, e
+// só quando algum predicado precisa deles
+type fileProbe struct {
+	fsys fs.FS
+	name string
+	info fs.FileInfo
+	head []byte
+	read bool
+}
+
+func (f *fileProbe) stat() (fs.FileInfo, error) {
+	if f.info == nil {
+		info, err := fs.Stat(f.fsys, f.name)
+		if err != nil {
+			return nil, err
+		}
+		f.info = info
+	}
+	return f.info, nil
+}
+
+func (f *fileProbe) start() ([]byte, error) {
+	if !f.read {
+		head, err := readHead(f.fsys, f.name)
+		if err != nil {
+			return nil, err
+		}
+		f.head, f.read = head, true
+	}
+	return f.head, nil
+}
+
+func firstLine(head []byte) []byte {
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+	return bytes.TrimSuffix(head, []byte("\r"))
+}
+
+// shebangMatches compara o interpretador do "#!" com want, ignorando a versão
+// no fim do nome
+func shebangMatches(line []byte, want string) bool {
+	interp := shebangInterpreter(line)
+	return interp != "" && (interp == want || strings.TrimRight(interp, "0123456789.") == want)
+}
+
+// shebangInterpreter retorna o nome do interpretador do "#!", pulando o env
+// ("#!/usr/bin/env -S python3 -u"); "" se a linha não é um shebang
+func shebangInterpreter(line []byte) string {
+	if !bytes.HasPrefix(line, []byte("#!")) {
+		return ""
+	}
+	fields := strings.Fields(string(line[2:]))
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := path.Base(fields[0])
+	if interp != "env" {
+		return interp
+	}
+	for _, f := range fields[1:] {
+		if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+			return path.Base(f)
+		}
+	}
+	return ""
+}
+
+// MatchFile classifica um arquivo de fsys em duas fases: primeiro só pelo
+// path, como Match; depois, se alguma regra de conteúdo que casa com o path
+// puder mudar o resultado, avalia os predicados com um stat e/ou a leitura do
+// início do arquivo. Arquivos sem regra de conteúdo relevante não são abertos,
+// a não ser os classificados sem extensão e sem linguagem conhecida, dos quais
+// o shebang é lido para preencher Language.
+func (m *UltraFastMatcher) MatchFile(fsys fs.FS, name string) (MatchResult, error) {
+	probe := &fileProbe{fsys: fsys, name: name}
+	result, err := m.matchFile(probe)
+	if err != nil {
+		return result, err
+	}
+	return withInterpreter(probe, result), nil
+}
+
+// withInterpreter usa o shebang como linguagem de arquivos classificados sem
+// extensão. A linguagem é só informativa: um arquivo ilegível fica sem ela.
+func withInterpreter(probe *fileProbe, r MatchResult) MatchResult {
+	if !r.Matched || r.Language != "" || path.Ext(probe.name) != "" {
+		return r
+	}
+	if head, err := probe.start(); err == nil {
+		r.Language = interpreterLanguage(firstLine(head))
+	}
+	return r
+}
+
+func (m *UltraFastMatcher) matchFile(probe *fileProbe) (MatchResult, error) {
+	result := m.Match(probe.name)
+	p := m.preparePath(probe.name)
+	passing, err := m.passingContent(probe, p)
+	if err != nil || len(passing) == 0 {
+		return result, err
+	}
+	return m.contentResult(p, passing), nil
+}
+
+// decideFile é matchFile sem cache para o path name, que pode ser diferente
+// do arquivo aberto pelo probe (ver HierarchicalMatcher); diz também se
+// alguma regra casou, como decide
+func (m *UltraFastMatcher) decideFile(probe *fileProbe, name string) (MatchResult, bool, error) {
+	result, decided := m.decide(name)
+	p := m.preparePath(name)
+	passing, err := m.passingContent(probe, p)
+	if err != nil || len(passing) == 0 {
+		return result, decided, err
+	}
+	return m.contentResult(p, passing), true, nil
+}
+
+// passingContent retorna as regras de conteúdo que casam com o path p e cujos
+// predicados o arquivo satisfaz, entre as que podem mudar o resultado só pelo
+// path. Sem prioridades basta a última delas.
+func (m *UltraFastMatcher) passingContent(probe *fileProbe, p string) ([]int, error) {
+	if len(m.contentRules) == 0 || p == "" || strings.HasSuffix(p, "/") {
+		return nil, nil
+	}
+
+	// No gitignore um diretório ancestral excluído decide antes do arquivo
+	if m.gitignore && !m.hasPriority && m.ancestorMatch(p) >= 0 {
+		return nil, nil
+	}
+
+	// Sem prioridades só regras depois da que decidiu podem mudar o resultado
+	decider := -1
+	if !m.hasPriority {
+		decider = m.lastMatch(p, false)
+	}
+	basename := filepath.Base(p)
+	var candidates []contentRule
+	for _, cr := range m.contentRules {
+		if cr.path.rule > decider && cr.path.matches(p, basename) {
+			candidates = append(candidates, cr)
+		}
+	}
+
+	// Da última para a primeira: sem prioridades a primeira que passa decide
+	var passing []int
+	for i := len(candidates) - 1; i >= 0; i-- {
+		ok, err := candidates[i].pred.matches(probe)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			passing = append(passing, candidates[i].path.rule)
+			if !m.hasPriority {
+				break
+			}
+		}
+	}
+	return passing, nil
+}
+
+// contentResult é o resultado quando regras de conteúdo passaram
+func (m *UltraFastMatcher) contentResult(p string, passing []int) MatchResult {
+	if m.hasPriority {
+		all := m.rankWinners(m.typeWinners(p, passing))
+		return withLanguage(p, MatchResult{Matched: all.Matched, Type: all.Primary})
+	}
+	return withLanguage(p, m.resultFor(passing[0]))
+}
+
+// readHead lê até contentHeadSize bytes do início do arquivo
+func readHead(fsys fs.FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, contentHeadSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return head[:n], nil
+}
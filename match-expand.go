@@ -0,0 +1,226 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Limites da expansão: acima deles o pattern continua como glob
+const (
+	maxExpansions = 64 // alternativas geradas por pattern
+	maxClassSize  = 8  // caracteres por classe "[...]"
+)
+
+// patternKind é o tier que compilePatterns escolhe para um pattern fora do
+// modo gitignore
+type patternKind int
+
+const (
+	kindGlob patternKind = iota
+	kindExtension
+	kindExact
+	kindPrefix
+	kindSuffix
+)
+
+// classifyPattern escolhe o tier mais rápido para o pattern
+func classifyPattern(pattern string) patternKind {
+	switch {
+	// 1. Extensões: *.go, *.js, *.test.go
+	case strings.HasPrefix(pattern, "*.") && !strings.ContainsAny(pattern[2:], "*?[]{}"):
+		return kindExtension
+
+	// 2. Paths exatos: "main.go", "src/app/main.go"
+	case !strings.ContainsAny(pattern, "*?[]{}"):
+		return kindExact
+
+	// 3. Prefixos: "src/*", "vendor/*", "node_modules/*"
+	case strings.HasSuffix(pattern, "/*") && !strings.ContainsAny(pattern[:len(pattern)-2], "*?[]{}"):
+		return kindPrefix
+
+	// 4. Sufixos: "*/test", "*/tests"
+	case strings.HasPrefix(pattern, "*/") && !strings.ContainsAny(pattern[2:], "*?[]{}"):
+		return kindSuffix
+	}
+	// 5. Patterns complexos (**, globs, etc.)
+	return kindGlob
+}
+
+// addFastPattern indexa o pattern no tier de kind
+func (m *UltraFastMatcher) addFastPattern(pattern string, kind patternKind, rule int, basenameOnly bool) {
+	switch kind {
+	case kindExtension:
+		m.addExtension(pattern[1:], rule) // Remove '*', mantém '.'
+
+	case kindExact:
+		m.addExactPath(pattern, rule)
+		if basenameOnly {
+			// Mantém o tier exact-path: o basename é só um atalho da mesma regra
+			basename := filepath.Base(pattern)
+			m.exactBasenames[basename] = append(m.exactBasenames[basename], rule)
+		}
+
+	case kindPrefix:
+		m.addPrefix(pattern[:len(pattern)-1], rule, false) // Remove '*'
+
+	case kindSuffix:
+		m.addSuffix(pattern[1:], rule) // Remove '*'
+	}
+}
+
+// expandFast expande chaves e classes pequenas do pattern ("*.{go,py}",
+// "src/{app,lib}/*", "[Mm]akefile") em alternativas que cabem nos tiers
+// rápidos. Retorna false se alguma alternativa ainda precisaria de glob ou
+// mudaria de sentido fora dele.
+func expandFast(pattern string, basenameOnly bool) ([]string, []patternKind, bool) {
+	if !strings.ContainsAny(pattern, "{[") || strings.Contains(pattern, "\\") {
+		return nil, nil, false
+	}
+	alts, ok := expandAlternatives(pattern)
+	if !ok {
+		return nil, nil, false
+	}
+
+	kinds := make([]patternKind, len(alts))
+	for i, alt := range alts {
+		kinds[i] = classifyPattern(alt)
+		switch {
+		case alt == "" || kinds[i] == kindGlob:
+			return nil, nil, false
+		// Como glob, "src/{a,b}.go" só casa com o path completo; no tier
+		// exato ele também casaria pelo basename
+		case kinds[i] == kindExact && basenameOnly && strings.Contains(alt, "/"):
+			return nil, nil, false
+		// Extensões são comparadas com o basename
+		case kinds[i] == kindExtension && strings.Contains(alt, "/"):
+			return nil, nil, false
+		}
+	}
+	return alts, kinds, true
+}
+
+// expandAlternatives gera as alternativas de todas as chaves e classes do
+// pattern, sem repetições
+func expandAlternatives(pattern string) ([]string, bool) {
+	i := strings.IndexAny(pattern, "{[")
+	if i < 0 {
+		return []string{pattern}, true
+	}
+
+	var options []string
+	var rest string
+	if pattern[i] == '[' {
+		end := strings.IndexByte(pattern[i+1:], ']')
+		if end < 0 {
+			return nil, false
+		}
+		class := pattern[i+1 : i+1+end]
+		var ok bool
+		if options, ok = expandClass(class); !ok {
+			return nil, false
+		}
+		rest = pattern[i+2+end:]
+	} else {
+		end, ok := closingBrace(pattern, i)
+		if !ok {
+			return nil, false
+		}
+		options = splitTopLevel(pattern[i+1 : end])
+		rest = pattern[end+1:]
+	}
+
+	var alts []string
+	for _, option := range options {
+		// Chaves aninhadas são expandidas na recursão
+		expanded, ok := expandAlternatives(pattern[:i] + option + rest)
+		if !ok {
+			return nil, false
+		}
+		for _, alt := range expanded {
+			if !slices.Contains(alts, alt) {
+				alts = append(alts, alt)
+			}
+		}
+		if len(alts) > maxExpansions {
+			return nil, false
+		}
+	}
+	return alts, true
+}
+
+// classMeta são os caracteres que, colados no pattern, mudariam de sentido
+const classMeta = "*?[]{}/\\"
+
+// expandClass lista os caracteres de "[abc]" ou "[a-c]"; classes negadas,
+// grandes ou com metacaracteres ("[*].go" é só o nome "*.go") não são
+// expandidas
+func expandClass(class string) ([]string, bool) {
+	if class == "" || class[0] == '!' || class[0] == '^' {
+		return nil, false
+	}
+	runes := []rune(class)
+	var chars []string
+	for j := 0; j < len(runes); j++ {
+		lo, hi := runes[j], runes[j]
+		if j+2 < len(runes) && runes[j+1] == '-' {
+			hi = runes[j+2]
+			j += 2
+		}
+		if hi < lo || int(hi-lo) >= maxClassSize {
+			return nil, false
+		}
+		for r := lo; r <= hi; r++ {
+			if strings.ContainsRune(classMeta, r) {
+				return nil, false
+			}
+			chars = append(chars, string(r))
+		}
+		if len(chars) > maxClassSize {
+			return nil, false
+		}
+	}
+	return chars, true
+}
+
+// closingBrace acha a "}" que fecha a "{" em open, considerando aninhamento
+func closingBrace(pattern string, open int) (int, bool) {
+	depth := 0
+	for j := open; j < len(pattern); j++ {
+		switch pattern[j] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return j, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// splitTopLevel separa as opções de uma chave pelas vírgulas fora de chaves
+// aninhadas
+func splitTopLevel(s string) []string {
+	var options []string
+	depth, start := 0, 0
+	for j := 0; j < len(s); j++ {
+		switch s[j] {
+		case '[':
+			// Vírgulas dentro de uma classe não separam opções
+			if end := strings.IndexByte(s[j+1:], ']'); end >= 0 {
+				j += end + 1
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				options = append(options, s[start:j])
+				start = j + 1
+			}
+		}
+	}
+	return append(options, s[start:])
+}
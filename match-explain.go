@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Explanation descreve por que um path foi (ou não) classificado
+type Explanation struct {
+	Path      string
+	Matched   bool
+	Type      string
+	Rule      *RuleRef  // regra que decidiu o resultado (nil se nenhuma casou)
+	Negations []RuleRef // negações que casaram com o path, em ordem
+}
+
+// RuleRef identifica uma regra e onde ela casou
+type RuleRef struct {
+	Pattern string
+	Type    string
+	Negated bool
+	Tier    string // exact-path, basename, extension, compound-extension, prefix, suffix, glob
+	Source  string
+	Line    int
+	Dir     string // gitignore: diretório ancestral que casou (vazio = o próprio path)
+}
+
+func (r RuleRef) String() string {
+	s := fmt.Sprintf("%s [%s]", r.Pattern, r.Tier)
+	if r.Dir != "" {
+		s += fmt.Sprintf(" em %s/", r.Dir)
+	}
+	if r.Source != "" {
+		s += fmt.Sprintf(" %s:%d", r.Source, r.Line)
+	}
+	return s
+}
+
+func (e Explanation) String() string {
+	var b strings.Builder
+	switch {
+	case e.Matched:
+		fmt.Fprintf(&b, "%s: %s\n", e.Path, e.Type)
+	case e.Rule != nil:
+		fmt.Fprintf(&b, "%s: excluído\n", e.Path)
+	default:
+		fmt.Fprintf(&b, "%s: sem match\n", e.Path)
+	}
+	if e.Rule != nil {
+		fmt.Fprintf(&b, "  regra:   %s\n", e.Rule)
+	}
+	for _, n := range e.Negations {
+		if e.Rule == nil || n != *e.Rule {
+			fmt.Fprintf(&b, "  negação: %s\n", n)
+		}
+	}
+	return b.String()
+}
+
+// ruleHit é uma regra que casou em um nível do path
+type ruleHit struct {
+	rule int
+	tier matchTier
+}
+
+// explainLevel agrupa as regras que casaram com um nível do path. Fora do modo
+// gitignore há um único nível; no gitignore cada diretório ancestral é um nível.
+type explainLevel struct {
+	path string
+	dir  bool // nível é um diretório ancestral
+	hits []ruleHit
+}
+
+// Explain mostra qual regra decidiu a classificação do path, em qual tier ela
+// casou, de onde ela veio e quais negações casaram. O resultado é o mesmo de
+// Match, mas calculado sem cache e percorrendo todos os tiers.
+func (m *UltraFastMatcher) Explain(path string) Explanation {
+	e := Explanation{Path: path}
+	path = m.preparePath(path)
+	levels := m.explainLevels(path)
+
+	for _, level := range levels {
+		for _, hit := range level.hits {
+			if m.rules[hit.rule].negated {
+				e.Negations = append(e.Negations, m.ruleRef(hit, level))
+			}
+		}
+	}
+
+	level, hit, ok := m.decidingHit(path, levels)
+	if !ok {
+		return e
+	}
+	ref := m.ruleRef(hit, level)
+	e.Rule = &ref
+	if !ref.Negated {
+		e.Matched, e.Type = true, ref.Type
+	}
+	return e
+}
+
+// decidingHit reproduz a decisão de Match sobre os níveis já coletados
+func (m *UltraFastMatcher) decidingHit(path string, levels []explainLevel) (explainLevel, ruleHit, bool) {
+	// Com prioridades vence o tipo primário de MatchAll
+	if m.hasPriority {
+		var best *typeWinner
+		for _, w := range m.typeWinners(path, nil) {
+			if best == nil || m.outranks(w, *best) {
+				w := w
+				best = &w
+			}
+		}
+		if best == nil {
+			return m.lastHit(levels[len(levels)-1])
+		}
+		for _, hit := range levels[best.depth].hits {
+			if hit.rule == best.rule {
+				return levels[best.depth], hit, true
+			}
+		}
+	}
+
+	// No gitignore o primeiro diretório ancestral excluído decide
+	for _, level := range levels[:len(levels)-1] {
+		if lvl, hit, ok := m.lastHit(level); ok && !m.rules[hit.rule].negated {
+			return lvl, hit, true
+		}
+	}
+	return m.lastHit(levels[len(levels)-1])
+}
+
+// lastHit retorna a regra de maior posição do nível
+func (m *UltraFastMatcher) lastHit(level explainLevel) (explainLevel, ruleHit, bool) {
+	if len(level.hits) == 0 {
+		return level, ruleHit{}, false
+	}
+	return level, level.hits[len(level.hits)-1], true
+}
+
+// explainLevels coleta as regras que casam em cada nível, ordenadas pela
+// posição e sem repetir regras
+func (m *UltraFastMatcher) explainLevels(path string) []explainLevel {
+	collect := func(p string, isDir, ancestor bool) explainLevel {
+		level := explainLevel{path: p, dir: ancestor}
+		seen := make(map[int]bool)
+		m.eachMatch(p, isDir, func(rule int, tier matchTier) {
+			if !seen[rule] {
+				seen[rule] = true
+				level.hits = append(level.hits, ruleHit{rule, tier})
+			}
+		})
+		sort.Slice(level.hits, func(i, j int) bool {
+			return level.hits[i].rule < level.hits[j].rule
+		})
+		return level
+	}
+
+	if !m.gitignore {
+		return []explainLevel{collect(path, false, false)}
+	}
+
+	isDir := strings.HasSuffix(path, "/")
+	path = strings.TrimRight(path, "/")
+	var levels []explainLevel
+	for i := 1; i < len(path); i++ {
+		if path[i] == '/' {
+			levels = append(levels, collect(path[:i], true, true))
+		}
+	}
+	return append(levels, collect(path, isDir, false))
+}
+
+func (m *UltraFastMatcher) ruleRef(hit ruleHit, level explainLevel) RuleRef {
+	rule := m.rules[hit.rule]
+	ref := RuleRef{
+		Pattern: rule.pattern,
+		Type:    rule.ptype,
+		Negated: rule.negated,
+		Tier:    hit.tier.String(),
+		Source:  rule.source,
+		Line:    rule.line,
+	}
+	if level.dir {
+		ref.Dir = level.path
+	}
+	return ref
+}
+
+// runExplain imprime a explicação de cada path
+func runExplain(m *UltraFastMatcher, paths []string, w io.Writer) {
+	for _, path := range paths {
+		fmt.Fprint(w, m.Explain(path))
+	}
+}
+
+// readPathList lê um path por linha, ignorando linhas vazias
+func readPathList(r io.Reader) ([]string, error) {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if path := strings.TrimSpace(scanner.Text()); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, scanner.Err()
+}
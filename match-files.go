@@ -1,10 +1,14 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
-	"sync"
 
 	"github.com/gobwas/glob"
 )
@@ -14,270 +18,511 @@ type TypedPattern struct {
 	Pattern   string
 	Type      string // "Code", "Doc", etc.
 	IsNegated bool   // true se o pattern começa com !
+	
+	// Priority escolhe o tipo primário quando vários tipos casam (maior vence;
+	// no empate vale a ordem das regras)
+	Priority int
+	
+	// Origem do pattern quando carregado de arquivo
+	Source string
+	Line   int
+	
+	// Predicados de conteúdo: a regra só casa se, além do path, o início do
+	// arquivo satisfaz todos os predicados preenchidos (ver MatchFile)
+	Shebang   string // interpretador do "#!"; "python" também casa com python3
+	Magic     string // bytes iniciais do arquivo
+	FirstLine string // regexp aplicada à primeira linha
+	
+	// Predicados de atributos, avaliados junto com os de conteúdo. Tamanho e
+	// modo só precisam de um stat; binário e gerado leem o início do arquivo.
+	MinSize    int64 // tamanho mínimo em bytes (0 = sem limite)
+	MaxSize    int64 // tamanho máximo em bytes (0 = sem limite)
+	Binary     bool  // contém byte NUL no início, como na detecção do git
+	Executable bool  // algum bit de execução ligado
+	Generated  bool  // marcador "Code generated ... DO NOT EDIT"
 }
 
 // MatchResult contém o resultado do match com tipo
 type MatchResult struct {
 	Matched bool
 	Type    string
+	
+	// Language vem da tabela de linguagens, não das regras (ver DetectLanguage);
+	// vazio quando o path não casou ou a linguagem é desconhecida
+	Language string
 }
 
 // UltraFastMatcher - Matcher super otimizado para TypedPatterns
 type UltraFastMatcher struct {
-	// HashMap lookups - O(1) - agora com tipos
-	exactPaths     map[string]string  // path -> type
-	exactBasenames map[string]string  // basename -> type
-	extensions     map[string]string  // ext -> type
+	// Todas as regras na ordem original - a última que casa vence
+	rules          []matchRule
 	
-	// Slice lookups - O(n) mas rápido - agora com tipos
-	prefixes       []typedPrefix
-	suffixes       []typedSuffix
+	// HashMap lookups - O(1) - índices das regras em ordem crescente
+	exactPaths     map[string][]int  // path -> regras
+	exactBasenames map[string][]int  // basename -> regras
+	extensions     map[string][]int  // ext -> regras
 	
-	// Para patterns complexos - com tipos
+	// Tries por segmento de path - O(profundidade do path)
+	prefixes       segmentTrie
+	suffixes       segmentTrie
+	
+	// Para patterns complexos - em ordem crescente de regra
 	compiledGlobs  []typedGlob
 	
-	// Patterns negados (!) - processados por último
-	negatedPatterns []TypedPattern
+	// Patterns negados (!) ficam nos mesmos tiers; só cancelam regras anteriores
+	negatedCount   int
+	
+	// Patterns com chaves ou classes expandidos nos tiers rápidos
+	expandedCount  int
+	
+	// Alguma regra tem Priority: Match passa a escolher o tipo primário
+	hasPriority    bool
+	
+	// Modo gitignore - diretórios excluídos excluem todo o conteúdo
+	gitignore      bool
+	
+	// CaseSensitive=false - patterns e paths passam por foldCase
+	caseFold       bool
 	
-	// Cache de resultados
-	resultCache    sync.Map // map[string]MatchResult
-	cacheEnabled   bool
+	// Normalização aplicada aos paths antes do cache
+	normalizePaths   bool
+	normalizeUnicode bool
+	
+	// Identifica os patterns e opções compilados (ver patternsDigest)
+	digest         string
+	
+	// Paralelismo de MatchStream e MatchBatchParallel
+	workers       int
+	preserveOrder bool
+	
+	// Regras com predicados de conteúdo, fora dos tiers (ver MatchFile)
+	contentRules   []contentRule
+	
+	// Cache LRU de resultados - nil se desabilitado
+	cache          *lruCache
 }
 
-type typedPrefix struct {
-	prefix string
-	ptype  string
+// matchRule é um pattern identificado pela posição na lista original
+type matchRule struct {
+	index    int
+	pattern  string // como foi escrito
+	ptype    string
+	negated  bool
+	dirOnly  bool   // gitignore: "dir/" só casa com diretórios
+	priority int
+	tier     matchTier
+	source   string
+	line     int
+	content  *contentPredicate // nil para regras só de path
 }
 
-type typedSuffix struct {
-	suffix string
-	ptype  string
+// matchTier identifica em qual estrutura a regra foi indexada
+type matchTier int
+
+const (
+	tierExactPath matchTier = iota
+	tierBasename
+	tierExtension
+	tierCompoundExtension
+	tierPrefix
+	tierSuffix
+	tierGlob
+	tierContent
+	tierRegex
+)
+
+func (t matchTier) String() string {
+	return [...]string{"exact-path", "basename", "extension", "compound-extension", "prefix", "suffix", "glob", "content", "regex"}[t]
 }
 
 type typedGlob struct {
-	glob  glob.Glob
-	ptype string
+	glob    glob.Glob
+	rule    int
+	target  globTarget
+	pattern string // forma compilada, usada por MatchDir
 }
 
+// globTarget define contra o que o glob é testado
+type globTarget int
+
+const (
+	globPathOrBase globTarget = iota // path completo ou basename
+	globBase                         // só basename (gitignore sem "/")
+	globPath                         // só path completo (gitignore ancorado ou glob com "/")
+	globRegex                        // regexp "re:" contra o path completo
+)
+
 // MatcherOptions - opções de configuração
 type MatcherOptions struct {
 	EnableCache        bool
 	CaseSensitive     bool
 	MatchBasenameOnly bool
+	
+	// Limite de entradas do cache (0 = 64K) e número de shards (0 = 16)
+	CacheSize   int
+	CacheShards int
+	
+	// Gitignore segue exatamente a semântica do gitignore(5): âncora com "/"
+	// inicial, regras só de diretório com "/" final, escapes "\!" e "\#",
+	// "*" sem cruzar "/" e a última regra que casa vence. Paths terminados
+	// em "/" são tratados como diretórios.
+	Gitignore bool
+	
+	// NormalizePaths limpa o path antes do match e do cache: "\" vira "/",
+	// "./" e "//" somem e a "/" final é removida (no modo Gitignore ela é
+	// mantida, pois marca diretório)
+	NormalizePaths bool
+	
+	// NormalizeUnicode converte paths e patterns para NFC, então nomes vindos
+	// do macOS (NFD) casam com os mesmos patterns
+	NormalizeUnicode bool
+	
+	// Workers de MatchStream e MatchBatchParallel (0 = runtime.NumCPU())
+	Workers int
+	
+	// PreserveOrder faz MatchStream emitir os resultados na ordem de entrada
+	PreserveOrder bool
+	
+	// Presets embutidos aplicados antes dos patterns, que têm precedência
+	// sobre eles (ver PresetNames)
+	Presets []string
 }
 
 // NewUltraFastMatcher cria matcher com TypedPatterns
 func NewUltraFastMatcher(patterns []TypedPattern, opts *MatcherOptions) (*UltraFastMatcher, error) {
 	if opts == nil {
-		opts = &MatcherOptions{
-			EnableCache:        true,
-			CaseSensitive:     true,
-			MatchBasenameOnly: true,
-		}
+		opts = defaultMatcherOptions()
 	}
 	
-	m := &UltraFastMatcher{
-		exactPaths:     make(map[string]string),
-		exactBasenames: make(map[string]string),
-		extensions:     make(map[string]string),
-		cacheEnabled:   opts.EnableCache,
+	presets, err := ExpandPresets(opts.Presets)
+	if err != nil {
+		return nil, err
 	}
 	
-	if err := m.compilePatterns(patterns, opts); err != nil {
+	m := newMatcher(opts)
+	if err := m.compilePatterns(append(presets, patterns...), opts); err != nil {
 		return nil, fmt.Errorf("failed to compile patterns: %w", err)
 	}
+	m.digest = patternsDigest(patterns, opts)
 	
 	return m, nil
 }
 
+func defaultMatcherOptions() *MatcherOptions {
+	return &MatcherOptions{
+		EnableCache:        true,
+		CaseSensitive:     true,
+		MatchBasenameOnly: true,
+		NormalizePaths:    true,
+		NormalizeUnicode:  true,
+	}
+}
+
+// newMatcher cria um matcher sem regras com as opções aplicadas
+func newMatcher(opts *MatcherOptions) *UltraFastMatcher {
+	m := &UltraFastMatcher{
+		exactPaths:     make(map[string][]int),
+		exactBasenames: make(map[string][]int),
+		extensions:     make(map[string][]int),
+		gitignore:      opts.Gitignore,
+		caseFold:       !opts.CaseSensitive,
+		normalizePaths:   opts.NormalizePaths,
+		normalizeUnicode: opts.NormalizeUnicode,
+		workers:          opts.Workers,
+		preserveOrder:    opts.PreserveOrder,
+	}
+	if m.workers <= 0 {
+		m.workers = runtime.NumCPU()
+	}
+	
+	if opts.EnableCache {
+		m.cache = newLRUCache(opts.CacheSize, opts.CacheShards)
+	}
+	return m
+}
+
 // compilePatterns categoriza TypedPatterns por tipo
 func (m *UltraFastMatcher) compilePatterns(patterns []TypedPattern, opts *MatcherOptions) error {
+	if opts.Gitignore {
+		for _, tp := range patterns {
+			if expr, negated, ok := regexPattern(tp); ok {
+				if err := m.compileRegexRule(tp, expr, negated, opts); err != nil {
+					return err
+				}
+				continue
+			}
+			
+			line := tp
+			if opts.NormalizeUnicode {
+				line.Pattern = normalizeUnicode(line.Pattern)
+			}
+			if !opts.CaseSensitive {
+				line.Pattern = foldCase(line.Pattern)
+			}
+			gr, ok := parseGitignorePattern(line)
+			if !ok {
+				continue
+			}
+			rule := newMatchRule(len(m.rules), tp, gr.negated)
+			rule.dirOnly = gr.dirOnly
+			m.addRule(rule)
+			if hasContentPredicates(tp) {
+				target := globPath
+				if gr.noDir {
+					target = globBase
+				}
+				if err := m.addContentRule(typedGlob{&gr, rule.index, target, gr.pattern}, tp); err != nil {
+					return err
+				}
+				continue
+			}
+			m.addGitignoreRule(gr, rule.index)
+		}
+		return nil
+	}
+	
 	for _, tp := range patterns {
-		pattern := tp.Pattern
-		if len(pattern) == 0 {
+		if expr, negated, ok := regexPattern(tp); ok {
+			if err := m.compileRegexRule(tp, expr, negated, opts); err != nil {
+				return err
+			}
 			continue
 		}
 		
-		// Processa patterns negados separadamente
-		if tp.IsNegated {
-			m.negatedPatterns = append(m.negatedPatterns, tp)
+		pattern, negated := tp.Pattern, tp.IsNegated
+		if strings.HasPrefix(pattern, "!") {
+			pattern, negated = pattern[1:], true
+		}
+		if len(pattern) == 0 {
 			continue
 		}
 		
-		// Case insensitive se necessário
+		// Negados entram nos mesmos tiers; a posição decide quem vence
+		rule := newMatchRule(len(m.rules), tp, negated)
+		m.addRule(rule)
+		
+		// Mesma forma Unicode e de caixa dos paths (ver preparePath)
+		if opts.NormalizeUnicode {
+			pattern = normalizeUnicode(pattern)
+		}
 		if !opts.CaseSensitive {
-			pattern = strings.ToLower(pattern)
+			pattern = foldCase(pattern)
 		}
 		
-		// Categoriza por tipo de pattern
-		switch {
-		// 1. Extensões: *.go, *.js, *.test.go
-		case strings.HasPrefix(pattern, "*.") && !strings.Contains(pattern[2:], "*"):
-			ext := pattern[1:] // Remove '*', mantém '.'
-			// Se já existe, mantém o primeiro (precedência)
-			if _, exists := m.extensions[ext]; !exists {
-				m.extensions[ext] = tp.Type
-			}
-			
-		// 2. Paths exatos: "main.go", "src/app/main.go"
-		case !strings.ContainsAny(pattern, "*?[]{}"):
-			if _, exists := m.exactPaths[pattern]; !exists {
-				m.exactPaths[pattern] = tp.Type
+		// Regras de conteúdo ficam fora dos tiers; o path é testado como glob
+		if hasContentPredicates(tp) {
+			g, err := compileContentGlob(pattern)
+			if err != nil {
+				return err
 			}
-			if opts.MatchBasenameOnly {
-				basename := filepath.Base(pattern)
-				if _, exists := m.exactBasenames[basename]; !exists {
-					m.exactBasenames[basename] = tp.Type
-				}
+			if err := m.addContentRule(typedGlob{g, rule.index, patternTarget(pattern), pattern}, tp); err != nil {
+				return err
 			}
-			
-		// 3. Prefixos: "src/*", "vendor/*", "node_modules/*"
-		case strings.HasSuffix(pattern, "/*") && !strings.Contains(pattern[:len(pattern)-2], "*"):
-			prefix := pattern[:len(pattern)-1] // Remove '*'
-			m.prefixes = append(m.prefixes, typedPrefix{prefix, tp.Type})
-			
-		// 4. Sufixos: "*/test", "*/tests"
-		case strings.HasPrefix(pattern, "*/") && !strings.Contains(pattern[2:], "*"):
-			suffix := pattern[1:] // Remove '*'
-			m.suffixes = append(m.suffixes, typedSuffix{suffix, tp.Type})
-			
-		// 5. Patterns complexos (**, globs, etc.)
-		default:
-			g, err := glob.Compile(pattern)
-			if err != nil {
-				return fmt.Errorf("failed to compile pattern %s: %w", pattern, err)
+			continue
+		}
+		
+		// Chaves e classes pequenas viram várias entradas dos tiers rápidos
+		if alts, kinds, ok := expandFast(pattern, opts.MatchBasenameOnly); ok {
+			for i, alt := range alts {
+				m.addFastPattern(alt, kinds[i], rule.index, opts.MatchBasenameOnly)
 			}
-			m.compiledGlobs = append(m.compiledGlobs, typedGlob{g, tp.Type})
+			m.expandedCount++
+			continue
 		}
+		
+		// Categoriza por tipo de pattern. Os tiers rápidos mantêm os atalhos
+		// de sempre: "dir/*" é todo o conteúdo de dir e "*/nome" casa em
+		// qualquer profundidade; nos globs "*" não atravessa "/" (ver
+		// convertDoubleStarPattern)
+		if kind := classifyPattern(pattern); kind != kindGlob {
+			m.addFastPattern(pattern, kind, rule.index, opts.MatchBasenameOnly)
+			continue
+		}
+		g, err := compileGlob(pattern)
+		if err != nil {
+			return err
+		}
+		m.addGlob(g, pattern, rule.index, patternTarget(pattern))
 	}
 	
 	return nil
 }
 
+func newMatchRule(index int, tp TypedPattern, negated bool) matchRule {
+	return matchRule{
+		index:    index,
+		pattern:  tp.Pattern,
+		ptype:    tp.Type,
+		negated:  negated,
+		priority: tp.Priority,
+		source:   tp.Source,
+		line:     tp.Line,
+	}
+}
+
+// addRule registra a regra na ordem original
+func (m *UltraFastMatcher) addRule(rule matchRule) {
+	m.rules = append(m.rules, rule)
+	if rule.negated {
+		m.negatedCount++
+	}
+	if rule.priority != 0 {
+		m.hasPriority = true
+	}
+}
+
+func (m *UltraFastMatcher) addExactPath(path string, rule int) {
+	m.exactPaths[path] = append(m.exactPaths[path], rule)
+	m.rules[rule].tier = tierExactPath
+}
+
+func (m *UltraFastMatcher) addBasename(basename string, rule int) {
+	m.exactBasenames[basename] = append(m.exactBasenames[basename], rule)
+	m.rules[rule].tier = tierBasename
+}
+
+func (m *UltraFastMatcher) addExtension(ext string, rule int) {
+	m.extensions[ext] = append(m.extensions[ext], rule)
+	m.rules[rule].tier = extensionTier(ext)
+}
+
+func (m *UltraFastMatcher) addGlob(g glob.Glob, pattern string, rule int, target globTarget) {
+	m.compiledGlobs = append(m.compiledGlobs, typedGlob{g, rule, target, pattern})
+	m.rules[rule].tier = tierGlob
+}
+
+// extensionTier separa extensões simples (.go) de compostas (.test.go)
+func extensionTier(ext string) matchTier {
+	if strings.Count(ext, ".") > 1 {
+		return tierCompoundExtension
+	}
+	return tierExtension
+}
+
 // Match verifica se path corresponde a algum pattern e retorna tipo
 func (m *UltraFastMatcher) Match(path string) MatchResult {
+	path = m.preparePath(path)
 	if len(path) == 0 {
-		return MatchResult{false, ""}
+		return MatchResult{}
 	}
 	
 	// 1. Verifica cache
-	if m.cacheEnabled {
-		if cached, ok := m.resultCache.Load(path); ok {
-			return cached.(MatchResult)
+	if m.cache != nil {
+		if cached, ok := m.cache.get(path); ok {
+			return cached
 		}
 	}
 	
-	result := m.doMatch(path)
+	result := withLanguage(path, m.doMatch(path))
 	
 	// 2. Salva no cache
-	if m.cacheEnabled {
-		m.resultCache.Store(path, result)
+	if m.cache != nil {
+		m.cache.put(path, result)
 	}
 	
 	return result
 }
 
+// preparePath deixa o path na mesma forma dos patterns compilados; o resultado
+// também é a chave do cache
+func (m *UltraFastMatcher) preparePath(path string) string {
+	if m.normalizePaths {
+		path = normalizePath(path, m.gitignore)
+	}
+	if m.normalizeUnicode {
+		path = normalizeUnicode(path)
+	}
+	if m.caseFold {
+		path = foldCase(path)
+	}
+	return path
+}
+
 // doMatch executa lógica de matching otimizada
 func (m *UltraFastMatcher) doMatch(path string) MatchResult {
-	// 1. Exact path match - O(1)
-	if ptype, exists := m.exactPaths[path]; exists {
-		return m.checkNegated(path, MatchResult{true, ptype})
+	// Com prioridades o tipo vencedor depende de todos os tipos que casam
+	if m.hasPriority {
+		all := m.matchAll(path)
+		return MatchResult{Matched: all.Matched, Type: all.Primary}
 	}
 	
+	if m.gitignore {
+		return m.matchGitignore(path)
+	}
+	return m.resultFor(m.lastMatch(path, false))
+}
+
+// resultFor converte a regra vencedora em resultado: negada ou ausente exclui
+func (m *UltraFastMatcher) resultFor(rule int) MatchResult {
+	if rule < 0 || m.rules[rule].negated {
+		return MatchResult{}
+	}
+	return MatchResult{Matched: true, Type: m.rules[rule].ptype}
+}
+
+// lastMatch retorna a última regra, positiva ou negada, que casa com o path
+// (-1 se nenhuma). Os tiers O(1) são consultados primeiro; os lineares são
+// percorridos do fim para o início e param assim que chegam em uma regra
+// anterior à melhor já encontrada, já que ela não poderia mais vencer.
+func (m *UltraFastMatcher) lastMatch(path string, isDir bool) int {
+	best := -1
+	
+	// 1. Exact path match - O(1)
+	best = m.lastApplicable(m.exactPaths[path], best, isDir)
+	
 	// 2. Exact basename match - O(1)
 	basename := filepath.Base(path)
-	if ptype, exists := m.exactBasenames[basename]; exists {
-		return m.checkNegated(path, MatchResult{true, ptype})
-	}
+	best = m.lastApplicable(m.exactBasenames[basename], best, isDir)
 	
-	// 3. Extension match - O(1)
-	if ext := filepath.Ext(path); ext != "" {
-		if ptype, exists := m.extensions[ext]; exists {
-			return m.checkNegated(path, MatchResult{true, ptype})
+	// 3. Extension match - O(1) por ponto no basename,
+	// cobre também extensões compostas (.test.go, .min.js)
+	for i := 0; i < len(basename); i++ {
+		if basename[i] == '.' {
+			best = m.lastApplicable(m.extensions[basename[i:]], best, isDir)
 		}
 	}
 	
-	// 4. Extensões compostas (.test.go, .min.js)
-	if dotIndex := strings.LastIndex(basename, "."); dotIndex > 0 {
-		if secondDot := strings.LastIndex(basename[:dotIndex], "."); secondDot > 0 {
-			compoundExt := basename[secondDot:]
-			if ptype, exists := m.extensions[compoundExt]; exists {
-				return m.checkNegated(path, MatchResult{true, ptype})
-			}
-		}
-	}
+	// 4. Prefix match - O(profundidade)
+	best = m.lastPrefix(path, best, isDir)
 	
-	// 5. Prefix match - O(n)
-	for _, tp := range m.prefixes {
-		if strings.HasPrefix(path, tp.prefix) {
-			return m.checkNegated(path, MatchResult{true, tp.ptype})
-		}
-	}
+	// 5. Suffix match - O(profundidade)
+	best = m.lastSuffix(path, best, isDir)
 	
-	// 6. Suffix match - O(n)
-	for _, ts := range m.suffixes {
-		if strings.HasSuffix(path, ts.suffix) {
-			return m.checkNegated(path, MatchResult{true, ts.ptype})
+	// 6. Complex glob patterns
+	for i := len(m.compiledGlobs) - 1; i >= 0 && m.compiledGlobs[i].rule > best; i-- {
+		if m.compiledGlobs[i].matches(path, basename) && m.applies(m.compiledGlobs[i].rule, isDir) {
+			best = m.compiledGlobs[i].rule
+			break
 		}
 	}
 	
-	// 7. Complex glob patterns
-	for _, tg := range m.compiledGlobs {
-		if tg.glob.Match(path) || tg.glob.Match(basename) {
-			return m.checkNegated(path, MatchResult{true, tg.ptype})
+	return best
+}
+
+// lastApplicable retorna a última regra da lista posterior a best que se aplica
+func (m *UltraFastMatcher) lastApplicable(rules []int, best int, isDir bool) int {
+	for i := len(rules) - 1; i >= 0 && rules[i] > best; i-- {
+		if m.applies(rules[i], isDir) {
+			return rules[i]
 		}
 	}
-	
-	return MatchResult{false, ""}
+	return best
 }
 
-// checkNegated verifica se algum pattern negado (!pattern) cancela o match
-func (m *UltraFastMatcher) checkNegated(path string, result MatchResult) MatchResult {
-	if !result.Matched {
-		return result
-	}
-	
-	basename := filepath.Base(path)
-	
-	for _, negated := range m.negatedPatterns {
-		pattern := negated.Pattern
-		
-		// Remove ! do início se existir
-		if strings.HasPrefix(pattern, "!") {
-			pattern = pattern[1:]
-		}
-		
-		// Verifica se o pattern negado faz match
-		matched := false
-		switch {
-		case strings.HasPrefix(pattern, "*.") && !strings.Contains(pattern[2:], "*"):
-			ext := pattern[1:]
-			matched = strings.HasSuffix(path, ext) || strings.HasSuffix(basename, ext)
-			
-		case !strings.ContainsAny(pattern, "*?[]{}"):
-			matched = (path == pattern) || (basename == pattern)
-			
-		case strings.HasSuffix(pattern, "/*"):
-			prefix := pattern[:len(pattern)-1]
-			matched = strings.HasPrefix(path, prefix)
-			
-		case strings.HasPrefix(pattern, "*/"):
-			suffix := pattern[1:]
-			matched = strings.HasSuffix(path, suffix)
-			
-		default:
-			if g, err := glob.Compile(pattern); err == nil {
-				matched = g.Match(path) || g.Match(basename)
-			}
-		}
-		
-		// Se pattern negado faz match, cancela o resultado
-		if matched {
-			return MatchResult{false, ""}
-		}
+// applies descarta regras só de diretório quando o path é um arquivo
+// (regras de conteúdo não entram nos tiers, então não passam por aqui)
+func (m *UltraFastMatcher) applies(rule int, isDir bool) bool {
+	return isDir || !m.rules[rule].dirOnly
+}
+
+func (tg typedGlob) matches(path, basename string) bool {
+	switch tg.target {
+	case globBase:
+		return tg.glob.Match(basename)
+	case globPath, globRegex:
+		return tg.glob.Match(path)
 	}
-	
-	return result
+	return tg.glob.Match(path) || tg.glob.Match(basename)
 }
 
 // MatchBatch processa múltiplos paths
@@ -296,34 +541,39 @@ func (m *UltraFastMatcher) MatchSimple(path string) bool {
 
 // ClearCache limpa cache de resultados
 func (m *UltraFastMatcher) ClearCache() {
-	if m.cacheEnabled {
-		m.resultCache.Range(func(key, value interface{}) bool {
-			m.resultCache.Delete(key)
-			return true
-		})
+	if m.cache != nil {
+		m.cache.clear()
 	}
 }
 
 // Stats retorna estatísticas do matcher
 func (m *UltraFastMatcher) Stats() MatcherStats {
-	cacheSize := 0
-	if m.cacheEnabled {
-		m.resultCache.Range(func(k, v interface{}) bool {
-			cacheSize++
-			return true
-		})
-	}
-	
-	return MatcherStats{
+	stats := MatcherStats{
 		ExactPaths:      len(m.exactPaths),
 		ExactBasenames:  len(m.exactBasenames),
 		Extensions:      len(m.extensions),
-		Prefixes:        len(m.prefixes),
-		Suffixes:        len(m.suffixes),
+		Prefixes:        m.prefixes.size,
+		Suffixes:        m.suffixes.size,
 		ComplexGlobs:    len(m.compiledGlobs),
-		NegatedPatterns: len(m.negatedPatterns),
-		CacheSize:       cacheSize,
+		NegatedPatterns: m.negatedCount,
+		ExpandedPatterns: m.expandedCount,
+		ContentRules:    len(m.contentRules),
+		RulesByType:     make(TypeCounts),
+	}
+	for _, rule := range m.rules {
+		if !rule.negated {
+			stats.RulesByType.Add(rule.ptype)
+		}
+	}
+	
+	if m.cache != nil {
+		stats.CacheSize = m.cache.len()
+		stats.CacheHits = m.cache.hits.Load()
+		stats.CacheMisses = m.cache.misses.Load()
+		stats.CacheEvictions = m.cache.evictions.Load()
 	}
+	
+	return stats
 }
 
 type MatcherStats struct {
@@ -334,20 +584,46 @@ type MatcherStats struct {
 	Suffixes        int
 	ComplexGlobs    int
 	NegatedPatterns int
+	ExpandedPatterns int // patterns com {} ou [] indexados nos tiers acima
+	ContentRules    int
+	RulesByType     TypeCounts // regras positivas por tipo, somadas nos tipos pais
 	CacheSize       int
+	CacheHits       uint64
+	CacheMisses     uint64
+	CacheEvictions  uint64
 }
 
 func (s MatcherStats) String() string {
 	return fmt.Sprintf(
 		"MatcherStats{ExactPaths: %d, ExactBasenames: %d, Extensions: %d, "+
-		"Prefixes: %d, Suffixes: %d, ComplexGlobs: %d, NegatedPatterns: %d, CacheSize: %d}",
+		"Prefixes: %d, Suffixes: %d, ComplexGlobs: %d, NegatedPatterns: %d, "+
+		"ExpandedPatterns: %d, ContentRules: %d, RulesByType: %v, "+
+		"CacheSize: %d, CacheHits: %d, CacheMisses: %d, CacheEvictions: %d}",
 		s.ExactPaths, s.ExactBasenames, s.Extensions, 
-		s.Prefixes, s.Suffixes, s.ComplexGlobs, s.NegatedPatterns, s.CacheSize,
+		s.Prefixes, s.Suffixes, s.ComplexGlobs, s.NegatedPatterns,
+		s.ExpandedPatterns, s.ContentRules, s.RulesByType,
+		s.CacheSize, s.CacheHits, s.CacheMisses, s.CacheEvictions,
 	)
 }
 
 // === EXEMPLO DE USO ===
 func main() {
+	patternFile := flag.String("patterns", "", "arquivo de patterns tipados (ex: exemplo.patterns)")
+	gitignore := flag.Bool("gitignore", false, "usa a semântica do .gitignore")
+	snapshot := flag.String("snapshot", "", "snapshot do matcher compilado, regravado quando os patterns mudam")
+	presets := flag.String("presets", "", "presets embutidos separados por vírgula (ex: go,docs,vcs-ignore)")
+	typeFilter := flag.String("type", "", "walk: só arquivos deste tipo ou de subtipos (ex: Code inclui Code/Test)")
+	nested := flag.Bool("nested", false, "walk: aplica também os arquivos "+nestedPatternsFile+" dos subdiretórios")
+	flag.Parse()
+	
+	// conformance: confere a semântica de "*" e "**" com a tabela de casos
+	if flag.Arg(0) == "conformance" {
+		if !runConformance(os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+	
 	// Patterns com tipos
 	patterns := []TypedPattern{
 		// Código
@@ -356,6 +632,9 @@ func main() {
 		{Pattern: "*.test.go", Type: "Code", IsNegated: false},
 		{Pattern: "src/*", Type: "Code", IsNegated: false},
 		
+		// Subtipo: conta também em Code
+		{Pattern: "*_test.go", Type: "Code/Test", IsNegated: false},
+		
 		// Documentação
 		{Pattern: "*.md", Type: "Doc", IsNegated: false},
 		{Pattern: "*.txt", Type: "Doc", IsNegated: false},
@@ -366,31 +645,125 @@ func main() {
 		{Pattern: "!node_modules/*", Type: "", IsNegated: true},
 		{Pattern: "!.git/*", Type: "", IsNegated: true},
 		
+		// Reincluído depois da negação (a última regra vence)
+		{Pattern: "node_modules/keep.js", Type: "Code", IsNegated: false},
+		
 		// Outros
 		{Pattern: "*.log", Type: "Log", IsNegated: false},
 		{Pattern: "*.tmp", Type: "Temp", IsNegated: false},
 	}
 	
+	// Ou carregados de um arquivo versionado junto com o código
+	if *patternFile != "" {
+		loaded, err := LoadPatternFile(*patternFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		patterns = loaded
+	}
+	
 	// Cria matcher
 	opts := &MatcherOptions{
 		EnableCache:        true,
 		CaseSensitive:     true,
 		MatchBasenameOnly: true,
+		Gitignore:         *gitignore,
+	}
+	if *presets != "" {
+		opts.Presets = strings.Split(*presets, ",")
+	}
+
+	// patterns lint: aponta regras inválidas, repetidas ou que nunca decidem;
+	// termina com erro se algum pattern não compila
+	if flag.Arg(0) == "patterns" && flag.Arg(1) == "lint" {
+		if !runLint(patterns, opts, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Com -snapshot a compilação só acontece quando os patterns mudaram
+	var matcher *UltraFastMatcher
+	var err error
+	if *snapshot != "" {
+		// Um cache somente leitura não impede o uso do matcher compilado
+		if matcher, err = CachedMatcher(*snapshot, patterns, opts); errors.Is(err, ErrSnapshotNotSaved) {
+			fmt.Fprintln(os.Stderr, "⚠️ ", err)
+			err = nil
+		}
+	} else {
+		matcher, err = NewUltraFastMatcher(patterns, opts)
 	}
-	
-	matcher, err := NewUltraFastMatcher(patterns, opts)
 	if err != nil {
 		panic(err)
 	}
 	
+	// explain [paths...]: mostra por que cada path foi classificado
+	// (sem paths, lê um por linha da entrada padrão)
+	if flag.Arg(0) == "explain" {
+		paths := flag.Args()[1:]
+		if len(paths) == 0 {
+			if paths, err = readPathList(os.Stdin); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		runExplain(matcher, paths, os.Stdout)
+		return
+	}
+	
+	// patterns test <golden>: compara a classificação com o arquivo golden e
+	// explica cada divergência
+	if flag.Arg(0) == "patterns" && flag.Arg(1) == "test" {
+		cases, err := LoadGoldenFile(flag.Arg(2))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if !runGoldenTest(matcher, flag.Arg(2), cases, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// walk [dir]: lista os arquivos classificados, sem ler subárvores excluídas
+	// (com -nested, aplicando os arquivos aninhados de cada diretório; com
+	// -type, só os tipos abaixo do informado)
+	if flag.Arg(0) == "walk" {
+		root := "."
+		if flag.NArg() > 1 {
+			root = flag.Arg(1)
+		}
+		show := func(path string, result MatchResult) error {
+			if *typeFilter != "" && !result.IsA(*typeFilter) {
+				return nil
+			}
+			fmt.Printf("%s [%s] %s\n", path, result.Type, result.Language)
+			return nil
+		}
+		if *nested {
+			err = NewHierarchicalMatcher(os.DirFS(root), matcher, opts).WalkMatches(".", show)
+		} else {
+			err = matcher.WalkMatches(os.DirFS(root), ".", show)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	
 	// Testa paths
 	testPaths := []string{
 		"main.go",                 // Code
+		"handler_test.go",        // Code/Test
 		"app.test.go",            // Code mas negado por !*.test.go
 		"README.md",              // Doc
 		"src/utils.js",           // Code (src/*)
 		"docs/guide.txt",         // Doc (docs/*)
 		"node_modules/lib.js",    // Negado por !node_modules/*
+		"node_modules/keep.js",   // Code (reincluído)
 		"app.log",                // Log
 		"temp.tmp",               // Temp
 		".git/config",            // Negado por !.git/*
@@ -419,48 +792,154 @@ func main() {
 	fmt.Println("\n⚡ Processamento em Batch:")
 	results := matcher.MatchBatch(testPaths)
 	
-	typeCount := make(map[string]int)
+	// Subtipos (Code/Test) também contam nos tipos pais
+	typeCount := make(TypeCounts)
+	languageCount := make(map[string]int)
 	for _, result := range results {
 		if result.Matched {
-			typeCount[result.Type]++
+			typeCount.Add(result.Type)
+			if result.Language != "" {
+				languageCount[result.Language]++
+			}
 		}
 	}
 	
 	fmt.Printf("Processados: %d paths\n", len(testPaths))
-	for ptype, count := range typeCount {
-		fmt.Printf("  %s: %d matches\n", ptype, count)
+	writeTypeCounts(os.Stdout, typeCount, "matches")
+	fmt.Println("Por linguagem:")
+	for language, count := range languageCount {
+		fmt.Printf("  %s: %d arquivos\n", language, count)
 	}
+	
+	// Multi-label: um arquivo conta em todos os seus tipos
+	fmt.Println("\n🏷️  Multi-label (MatchAll):")
+	multiPaths := append(testPaths, "docs/api_test.go")
+	allCount := make(TypeCounts)
+	for _, path := range multiPaths {
+		all := matcher.MatchAll(path)
+		allCount.Add(all.Types...)
+		if len(all.Types) > 1 {
+			fmt.Printf("  %s: %v (primário: %s)\n", path, all.Types, all.Primary)
+		}
+	}
+	writeTypeCounts(os.Stdout, allCount, "matches")
 }
 
 
 
 
 
-// convertDoubleStarPattern converte patterns com ** para sintaxe glob
-func convertDoubleStarPattern(pattern string) string {
-	// **/*.go -> {**/,}*.go (qualquer profundidade + raiz)
-	if strings.HasPrefix(pattern, "**/") {
-		suffix := pattern[3:] // Remove "**/"
-		return "{**/,}" + suffix
+// convertDoubleStarPattern converte patterns com ** nas alternativas que o
+// gobwas compila com "/" como separador: "*" fica dentro de um segmento e
+// "**" atravessa diretórios. Os "zero diretórios" viram alternativas sem o
+// "**", porque o gobwas erra com chaves que têm opção vazia ou "/".
+func convertDoubleStarPattern(pattern string) []string {
+	// **pattern** -> **/pattern/** (e as formas abaixo)
+	if len(pattern) > 4 && strings.HasPrefix(pattern, "**") && strings.HasSuffix(pattern, "**") &&
+		!strings.HasPrefix(pattern, "**/") && !strings.HasSuffix(pattern, "/**") {
+		pattern = "**/" + pattern[2:len(pattern)-2] + "/**"
 	}
 	
-	// *.go/** -> *.go{/**,}
-	if strings.HasSuffix(pattern, "/**") {
-		prefix := pattern[:len(pattern)-3] // Remove "/**"
-		return prefix + "{/**,}"
+	alts := []string{pattern}
+	
+	// **/*.go -> **/*.go, *.go (qualquer profundidade + raiz)
+	if strings.HasPrefix(pattern, "**/") && len(pattern) > 3 {
+		alts = append(alts, pattern[3:])
 	}
 	
-	// dir/**/file -> dir{/**/,/}file
-	if strings.Contains(pattern, "/**/") {
-		return strings.ReplaceAll(pattern, "/**/", "{/**/,/}")
+	// dir/** -> dir/**, dir
+	for _, alt := range alts {
+		if strings.HasSuffix(alt, "/**") && len(alt) > 3 {
+			alts = append(alts, alt[:len(alt)-3])
+		}
 	}
 	
-	// **pattern** -> {**/,}pattern{/**,}
-	if strings.HasPrefix(pattern, "**") && strings.HasSuffix(pattern, "**") {
-		middle := pattern[2 : len(pattern)-2]
-		return "{**/,}" + middle + "{/**,}"
+	// dir/**/file -> dir/**/file, dir/file
+	// Outros ** (a**b) continuam atravessando "/"
+	var out []string
+	for _, alt := range alts {
+		for _, collapsed := range collapseDoubleStar(alt, 0) {
+			if !slices.Contains(out, collapsed) {
+				out = append(out, collapsed)
+			}
+		}
+	}
+	return out
+}
+
+// collapseDoubleStar gera as combinações de cada "/**/" a partir de from
+// mantido ou trocado por "/"
+func collapseDoubleStar(pattern string, from int) []string {
+	i := strings.Index(pattern[from:], "/**/")
+	if i < 0 {
+		return []string{pattern}
+	}
+	i += from
+	kept := collapseDoubleStar(pattern, i+3)
+	return append(kept, collapseDoubleStar(pattern[:i]+pattern[i+3:], i)...)
+}
+
+// anyGlob casa se alguma das alternativas casar
+type anyGlob []glob.Glob
+
+func (a anyGlob) Match(s string) bool {
+	for _, g := range a {
+		if g.Match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob compila o pattern com a semântica de ** acima
+func compileGlob(pattern string) (glob.Glob, error) {
+	return compileAlternatives(pattern, convertDoubleStarPattern(pattern))
+}
+
+// patternTarget escolhe o que o glob testa: com "/" só o path completo, já
+// que "docs/**" também vira "docs" e não deve casar com o basename de "a/docs"
+func patternTarget(pattern string) globTarget {
+	if strings.Contains(pattern, "/") {
+		return globPath
+	}
+	return globPathOrBase
+}
+
+// compileContentGlob compila o path de uma regra de conteúdo com a mesma
+// semântica dos tiers rápidos: "dir/*" é "dir/**" e "*/nome" é "**/nome"
+func compileContentGlob(pattern string) (glob.Glob, error) {
+	alts, kinds, ok := expandFast(pattern, false)
+	if !ok {
+		alts, kinds = []string{pattern}, []patternKind{classifyPattern(pattern)}
 	}
 	
-	// Simples ** no meio -> substitui por **
-	return strings.ReplaceAll(pattern, "**", "**")
+	var globs []string
+	for i, alt := range alts {
+		switch kinds[i] {
+		case kindGlob:
+			globs = append(globs, convertDoubleStarPattern(alt)...)
+		case kindPrefix:
+			globs = append(globs, alt+"*")
+		case kindSuffix:
+			globs = append(globs, "*"+alt)
+		default:
+			globs = append(globs, alt)
+		}
+	}
+	return compileAlternatives(pattern, globs)
+}
+
+func compileAlternatives(pattern string, alts []string) (glob.Glob, error) {
+	globs := make(anyGlob, len(alts))
+	for i, alt := range alts {
+		g, err := glob.Compile(alt, '/')
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile pattern %s: %w", pattern, err)
+		}
+		globs[i] = g
+	}
+	if len(globs) == 1 {
+		return globs[0], nil
+	}
+	return globs, nil
 }
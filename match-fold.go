@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// foldCase aplica o case folding simples do Unicode: runes equivalentes sem
+// distinção de maiúsculas (A/a, Ó/ó, K/k/K) viram o mesmo representante.
+// É idempotente, então pode ser aplicado a patterns e paths já dobrados.
+func foldCase(s string) string {
+	// Atalho ASCII: minúsculas já são o representante
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c >= utf8.RuneSelf || ('A' <= c && c <= 'Z') {
+			break
+		}
+	}
+	if i == len(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteString(s[:i])
+	for _, r := range s[i:] {
+		b.WriteRune(foldRune(r))
+	}
+	return b.String()
+}
+
+// foldRune escolhe o representante da órbita de unicode.SimpleFold: a menor
+// rune da órbita, em minúscula
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return unicode.ToLower(min)
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"strings"
+)
+
+// gitignoreRule é um pattern interpretado segundo gitignore(5)
+type gitignoreRule struct {
+	pattern  string // sem "!", sem "/" inicial e sem "/" final
+	negated  bool
+	dirOnly  bool // pattern terminava com "/"
+	noDir    bool // sem "/": casa contra o basename em qualquer nível
+	endsWith bool // "*literal": comparação simples de sufixo
+	prefix   int  // tamanho da parte literal antes do primeiro caractere especial
+}
+
+// parseGitignorePattern interpreta um TypedPattern como uma linha de .gitignore.
+// Retorna false para linhas vazias e comentários.
+func parseGitignorePattern(tp TypedPattern) (gitignoreRule, bool) {
+	line := tp.Pattern
+	if line == "" || line[0] == '#' {
+		return gitignoreRule{}, false
+	}
+	line = trimTrailingSpaces(line)
+
+	rule := gitignoreRule{negated: tp.IsNegated}
+	if strings.HasPrefix(line, "!") {
+		rule.negated = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = line[:len(line)-1]
+	}
+	rule.noDir = !strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	// "**/x" sem outras barras equivale a "x" em qualquer nível
+	if strings.HasPrefix(line, "**/") && !strings.Contains(line[3:], "/") {
+		line = line[3:]
+		rule.noDir = true
+	}
+	if line == "" {
+		return gitignoreRule{}, false
+	}
+
+	rule.setPattern(line)
+	return rule, true
+}
+
+// setPattern guarda o pattern já limpo e os atalhos usados por Match
+func (r *gitignoreRule) setPattern(p string) {
+	r.pattern = p
+	r.prefix = simpleLength(p)
+	r.endsWith = p[0] == '*' && simpleLength(p[1:]) == len(p)-1
+}
+
+// addGitignoreRule coloca a regra no tier mais rápido que preserva a semântica
+// do git; o que não tem equivalente exato vai para o tier de globs
+func (m *UltraFastMatcher) addGitignoreRule(gr gitignoreRule, index int) {
+	p := gr.pattern
+	switch {
+	// Literal sem "/": basename em qualquer nível
+	case gr.noDir && gr.prefix == len(p):
+		m.addBasename(p, index)
+
+	// "*.ext" sem "/": sufixo do basename começando em um ponto
+	case gr.noDir && gr.endsWith && len(p) > 1 && p[1] == '.':
+		m.addExtension(p[1:], index)
+
+	case gr.noDir:
+		m.addGlob(&gr, p, index, globBase)
+
+	// Literal ancorado: path exato
+	case gr.prefix == len(p):
+		m.addExactPath(p, index)
+
+	// "dir/*": exatamente um nível abaixo de dir
+	case gr.prefix == len(p)-1 && strings.HasSuffix(p, "/*"):
+		m.addPrefix(p[:len(p)-1], index, true)
+
+	// "dir/**": qualquer coisa abaixo de dir
+	case gr.prefix == len(p)-2 && strings.HasSuffix(p, "/**"):
+		m.addPrefix(p[:len(p)-2], index, false)
+
+	default:
+		m.addGlob(&gr, p, index, globPath)
+	}
+}
+
+// trimTrailingSpaces remove espaços finais que não estejam escapados com "\"
+func trimTrailingSpaces(s string) string {
+	lastSpace := -1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ' ':
+			if lastSpace < 0 {
+				lastSpace = i
+			}
+		case '\\':
+			i++
+			if i == len(s) {
+				return s
+			}
+			lastSpace = -1
+		default:
+			lastSpace = -1
+		}
+	}
+	if lastSpace >= 0 {
+		return s[:lastSpace]
+	}
+	return s
+}
+
+// simpleLength retorna o tamanho do trecho inicial sem caracteres especiais
+func simpleLength(s string) int {
+	if i := strings.IndexAny(s, "*?[\\"); i >= 0 {
+		return i
+	}
+	return len(s)
+}
+
+// Match verifica o pattern contra o basename (patterns sem "/") ou contra o
+// path relativo à raiz, sem "/" final. Regras só de diretório são filtradas
+// por quem chama.
+func (r *gitignoreRule) Match(name string) bool {
+	if r.noDir {
+		switch {
+		case r.prefix == len(r.pattern):
+			return name == r.pattern
+		case r.endsWith:
+			return strings.HasSuffix(name, r.pattern[1:])
+		default:
+			return wildmatch(r.pattern, name, false)
+		}
+	}
+
+	// A parte literal é comparada antes; o restante vai para o wildmatch
+	// (como no git, um "**" logo após o literal conta como início do pattern)
+	pattern := r.pattern
+	if r.prefix > 0 {
+		if !strings.HasPrefix(name, pattern[:r.prefix]) {
+			return false
+		}
+		pattern, name = pattern[r.prefix:], name[r.prefix:]
+		if pattern == "" && name == "" {
+			return true
+		}
+	}
+	return wildmatch(pattern, name, true)
+}
+
+// matchGitignore avalia o path com a semântica do git: a última regra que casa
+// vence, e um diretório excluído exclui todo o seu conteúdo (não há como
+// reincluir arquivos abaixo dele). Paths terminados em "/" são diretórios.
+func (m *UltraFastMatcher) matchGitignore(path string) MatchResult {
+	isDir := strings.HasSuffix(path, "/")
+	path = strings.TrimRight(path, "/")
+	if path == "" {
+		return MatchResult{}
+	}
+
+	if r := m.ancestorMatch(path); r >= 0 {
+		return MatchResult{Matched: true, Type: m.rules[r].ptype}
+	}
+	return m.resultFor(m.lastMatch(path, isDir))
+}
+
+// ancestorMatch retorna a regra que excluiu o primeiro diretório ancestral
+// excluído do path (-1 se nenhum)
+func (m *UltraFastMatcher) ancestorMatch(path string) int {
+	for i := 1; i < len(path); i++ {
+		if path[i] != '/' {
+			continue
+		}
+		if r := m.lastMatch(path[:i], true); r >= 0 && !m.rules[r].negated {
+			return r
+		}
+	}
+	return -1
+}
+
+// === WILDMATCH ===
+// Port do wildmatch.c do git, usado pelo .gitignore: "*" e "?" não cruzam "/"
+// quando pathname=true, e "**" só é especial entre barras.
+
+const (
+	wmMatch = iota
+	wmNoMatch
+	wmAbortAll
+	wmAbortToStarStar
+)
+
+func wildmatch(pattern, text string, pathname bool) bool {
+	return dowild(pattern, text, pathname) == wmMatch
+}
+
+// charAt retorna 0 fora dos limites, imitando o terminador de string do C
+func charAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+func dowild(p, text string, pathname bool) int {
+	pi, ti := 0, 0
+	for ; pi < len(p); pi, ti = pi+1, ti+1 {
+		pch := p[pi]
+		tch := charAt(text, ti)
+		if ti >= len(text) && pch != '*' {
+			return wmAbortAll
+		}
+
+		switch pch {
+		case '\\':
+			// Literal com o próximo caractere
+			pi++
+			pch = charAt(p, pi)
+			if tch != pch {
+				return wmNoMatch
+			}
+
+		default:
+			if tch != pch {
+				return wmNoMatch
+			}
+
+		case '?':
+			if pathname && tch == '/' {
+				return wmNoMatch
+			}
+
+		case '*':
+			matchSlash := !pathname
+			pi++
+			if charAt(p, pi) == '*' {
+				prev := pi - 2
+				for pi++; charAt(p, pi) == '*'; pi++ {
+				}
+				if !pathname {
+					matchSlash = true
+				} else if (prev < 0 || p[prev] == '/') &&
+					(pi == len(p) || p[pi] == '/' || (p[pi] == '\\' && charAt(p, pi+1) == '/')) {
+					// "**/" pode casar com zero diretórios
+					if charAt(p, pi) == '/' && dowild(p[pi+1:], text[ti:], pathname) == wmMatch {
+						return wmMatch
+					}
+					matchSlash = true
+				} else {
+					matchSlash = false
+				}
+			}
+
+			if pi == len(p) {
+				// "**" final casa com tudo; "*" final só se não houver mais "/"
+				if !matchSlash && strings.IndexByte(text[ti:], '/') >= 0 {
+					return wmNoMatch
+				}
+				return wmMatch
+			}
+			if !matchSlash && p[pi] == '/' {
+				// "*" seguido de "/" consome o próximo diretório
+				slash := strings.IndexByte(text[ti:], '/')
+				if slash < 0 {
+					return wmNoMatch
+				}
+				ti += slash
+				continue
+			}
+
+			for ti < len(text) {
+				// Avança direto até o próximo literal do pattern
+				if c := p[pi]; c != '*' && c != '?' && c != '[' && c != '\\' {
+					for ti < len(text) && (matchSlash || text[ti] != '/') && text[ti] != c {
+						ti++
+					}
+					if charAt(text, ti) != c {
+						return wmNoMatch
+					}
+				}
+				if matched := dowild(p[pi:], text[ti:], pathname); matched != wmNoMatch {
+					if !matchSlash || matched != wmAbortToStarStar {
+						return matched
+					}
+				} else if !matchSlash && text[ti] == '/' {
+					return wmAbortToStarStar
+				}
+				ti++
+			}
+			return wmAbortAll
+
+		case '[':
+			pi++
+			pch = charAt(p, pi)
+			if pch == '^' {
+				pch = '!'
+			}
+			negated := pch == '!'
+			if negated {
+				pi++
+				pch = charAt(p, pi)
+			}
+			var prevCh byte
+			matched := false
+			for {
+				if pch == 0 {
+					return wmAbortAll
+				}
+				switch {
+				case pch == '\\':
+					pi++
+					pch = charAt(p, pi)
+					if pch == 0 {
+						return wmAbortAll
+					}
+					if tch == pch {
+						matched = true
+					}
+				case pch == '-' && prevCh != 0 && charAt(p, pi+1) != 0 && p[pi+1] != ']':
+					pi++
+					pch = p[pi]
+					if pch == '\\' {
+						pi++
+						pch = charAt(p, pi)
+						if pch == 0 {
+							return wmAbortAll
+						}
+					}
+					if tch <= pch && tch >= prevCh {
+						matched = true
+					}
+					pch = 0
+				case pch == '[' && charAt(p, pi+1) == ':':
+					pi += 2
+					s := pi
+					for pi < len(p) && p[pi] != ']' {
+						pi++
+					}
+					if pi == len(p) {
+						return wmAbortAll
+					}
+					if pi-s-1 < 0 || p[pi-1] != ':' {
+						// Sem ":]": trata como um conjunto normal
+						pi = s - 2
+						pch = '['
+						if tch == pch {
+							matched = true
+						}
+						break
+					}
+					ok, valid := matchCharClass(p[s:pi-1], tch)
+					if !valid {
+						return wmAbortAll
+					}
+					if ok {
+						matched = true
+					}
+					pch = 0
+				default:
+					if tch == pch {
+						matched = true
+					}
+				}
+				prevCh = pch
+				pi++
+				pch = charAt(p, pi)
+				if pch == ']' {
+					break
+				}
+			}
+			if matched == negated || (pathname && tch == '/') {
+				return wmNoMatch
+			}
+		}
+	}
+
+	if ti < len(text) {
+		return wmNoMatch
+	}
+	return wmMatch
+}
+
+// matchCharClass avalia classes POSIX como [:alpha:]
+func matchCharClass(class string, c byte) (matched bool, valid bool) {
+	isUpper := c >= 'A' && c <= 'Z'
+	isLower := c >= 'a' && c <= 'z'
+	isDigit := c >= '0' && c <= '9'
+	isPrint := c >= 0x20 && c < 0x7f
+
+	switch class {
+	case "alnum":
+		return isUpper || isLower || isDigit, true
+	case "alpha":
+		return isUpper || isLower, true
+	case "blank":
+		return c == ' ' || c == '\t', true
+	case "cntrl":
+		return c < 0x20 || c == 0x7f, true
+	case "digit":
+		return isDigit, true
+	case "graph":
+		return isPrint && c != ' ', true
+	case "lower":
+		return isLower, true
+	case "print":
+		return isPrint, true
+	case "punct":
+		return isPrint && c != ' ' && !isUpper && !isLower && !isDigit, true
+	case "space":
+		return c == ' ' || (c >= '\t' && c <= '\r'), true
+	case "upper":
+		return isUpper, true
+	case "xdigit":
+		return isDigit || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'), true
+	}
+	return false, false
+}
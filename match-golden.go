@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Formato do arquivo golden, uma classificação esperada por linha:
+//
+//	# comentário
+//	cmd/main.go -> Code
+//	docs/guia.md -> Doc
+//	vendor/lib.go -> excluded
+//
+// "excluded" espera que o path não seja classificado. Rodar o arquivo junto
+// com os patterns deixa a mudança de uma regra visível no review.
+
+// goldenExcluded é o valor esperado para paths não classificados
+const goldenExcluded = "excluded"
+
+// GoldenCase é uma linha do arquivo golden
+type GoldenCase struct {
+	Path string
+	Want string // tipo esperado ou goldenExcluded
+	Line int
+}
+
+// GoldenFailure é um caso cujo resultado diverge do esperado
+type GoldenFailure struct {
+	Case        GoldenCase
+	Got         string
+	Explanation Explanation
+}
+
+// LoadGoldenFile lê um arquivo golden
+func LoadGoldenFile(path string) ([]GoldenCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGolden(data, path)
+}
+
+// ParseGolden interpreta o conteúdo de um arquivo golden; source é usado nas
+// mensagens de erro
+func ParseGolden(data []byte, source string) ([]GoldenCase, error) {
+	var cases []GoldenCase
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		path, want, ok := strings.Cut(line, "->")
+		path, want = strings.TrimSpace(path), strings.TrimSpace(want)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("%s:%d: expected \"path -> type\", got %q", source, lineNo, line)
+		}
+		cases = append(cases, GoldenCase{path, want, lineNo})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return cases, nil
+}
+
+// goldenValue escreve um resultado do jeito do arquivo golden
+func goldenValue(r MatchResult) string {
+	if !r.Matched {
+		return goldenExcluded
+	}
+	return r.Type
+}
+
+// CheckGolden classifica os paths dos casos e retorna os que divergem, com a
+// explicação do resultado obtido
+func (m *UltraFastMatcher) CheckGolden(cases []GoldenCase) []GoldenFailure {
+	var failures []GoldenFailure
+	for _, c := range cases {
+		if got := goldenValue(m.Match(c.Path)); got != c.Want {
+			failures = append(failures, GoldenFailure{c, got, m.Explain(c.Path)})
+		}
+	}
+	return failures
+}
+
+// runGoldenTest imprime as divergências como um diff; usado pelo comando
+// "patterns test"
+func runGoldenTest(m *UltraFastMatcher, source string, cases []GoldenCase, w io.Writer) bool {
+	failures := m.CheckGolden(cases)
+	for _, f := range failures {
+		fmt.Fprintf(w, "%s:%d:\n", source, f.Case.Line)
+		fmt.Fprintf(w, "- %s -> %s\n", f.Case.Path, f.Case.Want)
+		fmt.Fprintf(w, "+ %s -> %s\n", f.Case.Path, f.Got)
+		for _, line := range strings.Split(strings.TrimSuffix(f.Explanation.String(), "\n"), "\n") {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(failures) > 0 {
+		fmt.Fprintf(w, "❌ %d de %d casos falharam\n", len(failures), len(cases))
+		return false
+	}
+	fmt.Fprintf(w, "✅ %d casos ok\n", len(cases))
+	return true
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// nestedPatternsFile é o arquivo de patterns procurado em cada diretório
+const nestedPatternsFile = ".codesearch-patterns"
+
+// HierarchicalMatcher combina os patterns da raiz com arquivos
+// ".codesearch-patterns" espalhados pela árvore, como .gitignore aninhados:
+// os patterns de services/billing/.codesearch-patterns são relativos a
+// services/billing/, só valem abaixo dele e têm precedência sobre os dos
+// diretórios acima. O arquivo mais fundo com alguma regra (positiva ou
+// negada) que casa decide o path; prioridades valem dentro de cada arquivo.
+// Regras de conteúdo e de atributos ("!* @binary") valem em todos os níveis,
+// como em MatchFile.
+//
+// Os arquivos são descobertos na primeira vez que um diretório é visitado e
+// o matcher compilado de cada diretório fica em cache.
+type HierarchicalMatcher struct {
+	base *UltraFastMatcher
+	fsys fs.FS
+	opts *MatcherOptions
+
+	mu     sync.Mutex
+	layers map[string]*UltraFastMatcher // diretório -> matcher do seu arquivo (nil = sem arquivo)
+}
+
+// NewHierarchicalMatcher usa base para os patterns da raiz e procura os
+// arquivos aninhados em fsys. opts é usado para compilar os arquivos
+// aninhados; os Presets ficam só na raiz.
+func NewHierarchicalMatcher(fsys fs.FS, base *UltraFastMatcher, opts *MatcherOptions) *HierarchicalMatcher {
+	if opts == nil {
+		opts = defaultMatcherOptions()
+	}
+	nested := *opts
+	nested.Presets = nil
+	nested.EnableCache = false // decideFile não usa o cache
+
+	return &HierarchicalMatcher{
+		base:   base,
+		fsys:   fsys,
+		opts:   &nested,
+		layers: make(map[string]*UltraFastMatcher),
+	}
+}
+
+// Match classifica o arquivo path de fsys com o arquivo aninhado mais fundo
+// que o decide, ou com os patterns da raiz. Como em MatchFile, o arquivo só é
+// aberto se alguma regra de conteúdo puder mudar o resultado. Retorna erro se
+// algum arquivo de patterns no caminho não puder ser lido ou compilado.
+func (h *HierarchicalMatcher) Match(path string) (MatchResult, error) {
+	path = normalizePath(path, h.opts.Gitignore)
+	return h.matchProbe(&fileProbe{fsys: h.fsys, name: path})
+}
+
+// matchProbe percorre os níveis do mais fundo para a raiz; o probe é
+// compartilhado, então o arquivo é lido no máximo uma vez
+func (h *HierarchicalMatcher) matchProbe(probe *fileProbe) (MatchResult, error) {
+	path := probe.name
+	dirs := parentDirs(strings.TrimRight(path, "/"))
+	for i := len(dirs) - 1; i >= 0; i-- {
+		layer, err := h.layer(dirs[i])
+		if err != nil {
+			return MatchResult{}, err
+		}
+		if layer == nil {
+			continue
+		}
+		r, ok, err := layer.decideFile(probe, relativeTo(path, dirs[i]))
+		if err != nil {
+			return MatchResult{}, err
+		}
+		if ok {
+			return withLanguage(path, r), nil
+		}
+	}
+	return h.base.matchFile(probe)
+}
+
+// WalkMatches percorre a árvore a partir de root como
+// UltraFastMatcher.WalkMatches, carregando os arquivos aninhados de cada
+// diretório visitado. Como no git, um diretório só é pulado quando uma
+// negação o cobre e nenhum nível tem regra positiva que o alcance; arquivos
+// aninhados dentro dele não são lidos. Um diretório que a raiz apenas não
+// menciona é visitado, porque pode ter seu próprio arquivo de patterns.
+func (h *HierarchicalMatcher) WalkMatches(root string, fn func(path string, result MatchResult) error) error {
+	return fs.WalkDir(h.fsys, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			excluded, err := h.dirExcluded(path)
+			if err != nil {
+				return err
+			}
+			if excluded {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		probe := &fileProbe{fsys: h.fsys, name: path}
+		result, err := h.matchProbe(probe)
+		if err != nil {
+			return err
+		}
+		if result.Matched {
+			return fn(path, withInterpreter(probe, result))
+		}
+		return nil
+	})
+}
+
+// dirExcluded diz se dir pode ser pulado: nenhum nível (raiz, arquivos acima
+// de dir e o do próprio dir) tem regra positiva que o alcance e pelo menos um
+// deles tem uma negação que o cobre
+func (h *HierarchicalMatcher) dirExcluded(dir string) (bool, error) {
+	dir = strings.TrimRight(normalizePath(dir, false), "/")
+	if dir == "" {
+		dir = "."
+	}
+	if h.base.MatchDir(dir).State != DirExcluded {
+		return false, nil
+	}
+	negated := h.base.dirNegated(dir)
+
+	dirs := parentDirs(dir)
+	if dir != "." {
+		dirs = append(dirs, dir)
+	}
+	for _, parent := range dirs {
+		layer, err := h.layer(parent)
+		if err != nil {
+			return false, err
+		}
+		if layer == nil {
+			continue
+		}
+		rel := relativeTo(dir, parent)
+		if layer.MatchDir(rel).State != DirExcluded {
+			return false, nil
+		}
+		negated = negated || layer.dirNegated(rel)
+	}
+	return negated, nil
+}
+
+// layer retorna o matcher do arquivo aninhado de dir (nil se não houver),
+// lendo e compilando o arquivo só na primeira vez
+func (h *HierarchicalMatcher) layer(dir string) (*UltraFastMatcher, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if m, ok := h.layers[dir]; ok {
+		return m, nil
+	}
+
+	name := nestedPatternsFile
+	if dir != "." {
+		name = dir + "/" + nestedPatternsFile
+	}
+	l := &patternLoader{
+		readFile: func(name string) ([]byte, error) { return fs.ReadFile(h.fsys, name) },
+		visiting: make(map[string]bool),
+	}
+	patterns, err := l.load(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		h.layers[dir] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := NewUltraFastMatcher(patterns, h.opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	h.layers[dir] = m
+	return m, nil
+}
+
+// decide classifica o path como Match, sem cache, e diz também se alguma
+// regra casou com ele (uma negação que casa decide e exclui)
+func (m *UltraFastMatcher) decide(path string) (MatchResult, bool) {
+	if m.hasPriority || m.gitignore {
+		e := m.Explain(path)
+		return MatchResult{Matched: e.Matched, Type: e.Type}, e.Rule != nil
+	}
+
+	path = m.preparePath(path)
+	if path == "" {
+		return MatchResult{}, false
+	}
+	rule := m.lastMatch(path, false)
+	return m.resultFor(rule), rule >= 0
+}
+
+// dirNegated diz se alguma negação cobre o diretório inteiro ("!vendor/**",
+// ou "!vendor/" no gitignore). A raiz nunca é negada.
+func (m *UltraFastMatcher) dirNegated(dir string) bool {
+	dir = strings.TrimRight(m.preparePath(dir), "/")
+	if dir == "" || dir == "." {
+		return false
+	}
+	d := dir + "/"
+	if m.gitignore {
+		if r, ok := m.decide(d); ok && !r.Matched {
+			return true
+		}
+	}
+
+	reach := m.subtreeReach(d)
+	for r, rule := range m.rules {
+		if rule.negated && reach[r].covers {
+			return true
+		}
+	}
+	return false
+}
+
+// parentDirs lista os diretórios que contêm o path, da raiz (".") até o pai
+func parentDirs(path string) []string {
+	dirs := []string{"."}
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && i > 0 {
+			dirs = append(dirs, path[:i])
+		}
+	}
+	return dirs
+}
+
+// relativeTo remove o diretório dir do início do path ("" se path == dir)
+func relativeTo(path, dir string) string {
+	if dir == "." {
+		return path
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(path, dir), "/")
+}
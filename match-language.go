@@ -0,0 +1,167 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// Tabela de linguagens no estilo do Linguist: nome de arquivo, extensão e
+// interpretador do shebang. Nomes e extensões ficam em minúsculas, então a
+// detecção funciona também com CaseSensitive=false.
+
+var languageFilenames = map[string]string{
+	"makefile":       "Makefile",
+	"gnumakefile":    "Makefile",
+	"dockerfile":     "Dockerfile",
+	"containerfile":  "Dockerfile",
+	"jenkinsfile":    "Groovy",
+	"vagrantfile":    "Ruby",
+	"gemfile":        "Ruby",
+	"rakefile":       "Ruby",
+	"cmakelists.txt": "CMake",
+	"go.mod":         "Go Module",
+	"go.sum":         "Go Checksums",
+	"package.json":   "JSON",
+	"build.gradle":   "Gradle",
+	".bashrc":        "Shell",
+	".zshrc":         "Shell",
+	".profile":       "Shell",
+	".vimrc":         "Vim Script",
+}
+
+var languageExtensions = map[string]string{
+	".go":      "Go",
+	".py":      "Python",
+	".pyi":     "Python",
+	".pyw":     "Python",
+	".ipynb":   "Jupyter Notebook",
+	".js":      "JavaScript",
+	".mjs":     "JavaScript",
+	".cjs":     "JavaScript",
+	".jsx":     "JavaScript",
+	".ts":      "TypeScript",
+	".mts":     "TypeScript",
+	".cts":     "TypeScript",
+	".tsx":     "TSX",
+	".java":    "Java",
+	".kt":      "Kotlin",
+	".kts":     "Kotlin",
+	".scala":   "Scala",
+	".groovy":  "Groovy",
+	".gradle":  "Gradle",
+	".c":       "C",
+	".h":       "C",
+	".cc":      "C++",
+	".cpp":     "C++",
+	".cxx":     "C++",
+	".hpp":     "C++",
+	".hh":      "C++",
+	".cs":      "C#",
+	".fs":      "F#",
+	".rs":      "Rust",
+	".swift":   "Swift",
+	".m":       "Objective-C",
+	".rb":      "Ruby",
+	".php":     "PHP",
+	".pl":      "Perl",
+	".pm":      "Perl",
+	".lua":     "Lua",
+	".r":       "R",
+	".jl":      "Julia",
+	".dart":    "Dart",
+	".ex":      "Elixir",
+	".exs":     "Elixir",
+	".erl":     "Erlang",
+	".hs":      "Haskell",
+	".clj":     "Clojure",
+	".ml":      "OCaml",
+	".zig":     "Zig",
+	".nim":     "Nim",
+	".sh":      "Shell",
+	".bash":    "Shell",
+	".zsh":     "Shell",
+	".fish":    "fish",
+	".ps1":     "PowerShell",
+	".bat":     "Batchfile",
+	".cmd":     "Batchfile",
+	".sql":     "SQL",
+	".html":    "HTML",
+	".htm":     "HTML",
+	".css":     "CSS",
+	".scss":    "SCSS",
+	".sass":    "Sass",
+	".less":    "Less",
+	".vue":     "Vue",
+	".svelte":  "Svelte",
+	".md":      "Markdown",
+	".mdx":     "MDX",
+	".rst":     "reStructuredText",
+	".adoc":    "AsciiDoc",
+	".tex":     "TeX",
+	".txt":     "Text",
+	".json":    "JSON",
+	".yml":     "YAML",
+	".yaml":    "YAML",
+	".toml":    "TOML",
+	".xml":     "XML",
+	".ini":     "INI",
+	".cfg":     "INI",
+	".csv":     "CSV",
+	".proto":   "Protocol Buffer",
+	".graphql": "GraphQL",
+	".tf":      "HCL",
+	".tfvars":  "HCL",
+	".hcl":     "HCL",
+	".nix":     "Nix",
+	".vim":     "Vim Script",
+	".drawio":  "draw.io",
+}
+
+var languageInterpreters = map[string]string{
+	"python":  "Python",
+	"node":    "JavaScript",
+	"deno":    "TypeScript",
+	"bun":     "JavaScript",
+	"sh":      "Shell",
+	"bash":    "Shell",
+	"zsh":     "Shell",
+	"dash":    "Shell",
+	"ksh":     "Shell",
+	"fish":    "fish",
+	"ruby":    "Ruby",
+	"perl":    "Perl",
+	"php":     "PHP",
+	"lua":     "Lua",
+	"Rscript": "R",
+	"pwsh":    "PowerShell",
+	"awk":     "Awk",
+	"tclsh":   "Tcl",
+}
+
+// DetectLanguage retorna a linguagem de um path pelo nome do arquivo ou pela
+// extensão ("" se desconhecida). Não lê o arquivo; o shebang só é usado por
+// MatchFile.
+func DetectLanguage(name string) string {
+	base := strings.ToLower(path.Base(strings.TrimRight(name, "/")))
+	if lang, ok := languageFilenames[base]; ok {
+		return lang
+	}
+	return languageExtensions[path.Ext(base)]
+}
+
+// interpreterLanguage retorna a linguagem do interpretador do "#!"
+func interpreterLanguage(line []byte) string {
+	interp := shebangInterpreter(line)
+	if lang, ok := languageInterpreters[interp]; ok {
+		return lang
+	}
+	return languageInterpreters[strings.TrimRight(interp, "0123456789.")]
+}
+
+// withLanguage preenche a linguagem de um resultado classificado
+func withLanguage(name string, r MatchResult) MatchResult {
+	if r.Matched {
+		r.Language = DetectLanguage(name)
+	}
+	return r
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Severity indica se um diagnóstico impede o uso dos patterns
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "erro"
+	}
+	return "aviso"
+}
+
+// Tipos de diagnóstico
+const (
+	LintInvalidGlob     = "invalid-glob"     // pattern não compila
+	LintDuplicate       = "duplicate"        // repete uma regra anterior
+	LintConflictingType = "conflicting-type" // mesmo pattern redefinido com outro tipo
+	LintUnreachable     = "unreachable"      // a regra nunca decide o resultado de Match
+	LintBroadNegation   = "broad-negation"   // negação que casa com qualquer path
+)
+
+// Diagnostic é um problema encontrado por LintPatterns
+type Diagnostic struct {
+	Severity Severity
+	Kind     string
+	Rule     RuleRef
+	Related  *RuleRef // regra que causa o problema, quando há uma
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	where := d.Rule.Pattern
+	if d.Rule.Source != "" {
+		where = fmt.Sprintf("%s:%d: %s", d.Rule.Source, d.Rule.Line, d.Rule.Pattern)
+	}
+	if where == "" {
+		return fmt.Sprintf("%s [%s]: %s", d.Severity, d.Kind, d.Message)
+	}
+	return fmt.Sprintf("%s: %s [%s]: %s", where, d.Severity, d.Kind, d.Message)
+}
+
+// lintRule é o que o linter sabe sobre os paths aceitos por uma regra. As
+// formas são conservadoras: um campo vazio significa "não sei".
+type lintRule struct {
+	tp      TypedPattern
+	ref     RuleRef
+	key     string // pattern na forma compilada, sem "!"
+	negated bool
+	content bool
+	dirOnly bool
+
+	// Limites dos paths que a regra aceita
+	suffix string // todo path aceito termina com suffix
+	prefix string // todo path aceito começa com prefix (diretório, termina em "/")
+
+	// Conjuntos que a regra aceita por inteiro
+	all      bool   // qualquer path
+	endsWith string // todo path terminado em endsWith
+	under    string // todo path abaixo do diretório under (termina em "/")
+}
+
+// LintPatterns analisa os patterns sem classificar nenhum path e aponta
+// regras inválidas, repetidas, que nunca decidem o resultado ou negações que
+// nunca excluem nada. As análises de alcance só reportam o que é garantido;
+// com prioridades elas são puladas, porque o tipo primário não depende só da
+// ordem.
+func LintPatterns(patterns []TypedPattern, opts *MatcherOptions) []Diagnostic {
+	if opts == nil {
+		opts = defaultMatcherOptions()
+	}
+
+	// Os presets entram como regras comuns, para valerem os mesmos índices
+	presets, err := ExpandPresets(opts.Presets)
+	if err != nil {
+		return []Diagnostic{{SeverityError, LintInvalidGlob, RuleRef{}, nil, err.Error()}}
+	}
+	patterns = append(presets, patterns...)
+	withoutPresets := *opts
+	withoutPresets.Presets = nil
+	opts = &withoutPresets
+
+	var diags []Diagnostic
+	var rules []lintRule
+	var kept []TypedPattern
+	hasPriority := false
+	for _, tp := range patterns {
+		lr, ok := newLintRule(tp, opts)
+		if !ok {
+			continue
+		}
+		if err := newMatcher(opts).compilePatterns([]TypedPattern{tp}, opts); err != nil {
+			diags = append(diags, Diagnostic{SeverityError, LintInvalidGlob, lr.ref, nil, err.Error()})
+			continue
+		}
+		rules = append(rules, lr)
+		kept = append(kept, tp)
+		hasPriority = hasPriority || tp.Priority != 0
+	}
+
+	// Os índices das regras compiladas batem com os de rules
+	m, err := NewUltraFastMatcher(kept, opts)
+	if err != nil {
+		return append(diags, Diagnostic{SeverityError, LintInvalidGlob, RuleRef{}, nil, err.Error()})
+	}
+	for i := range rules {
+		rules[i].ref.Tier = m.rules[i].tier.String()
+	}
+
+	for i := range rules {
+		if d, ok := lintRuleAt(m, rules, i, hasPriority); ok {
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// lintRuleAt reporta no máximo um problema por regra, o mais específico
+func lintRuleAt(m *UltraFastMatcher, rules []lintRule, i int, hasPriority bool) (Diagnostic, bool) {
+	r := &rules[i]
+	warn := func(kind string, related *lintRule, format string, args ...any) (Diagnostic, bool) {
+		d := Diagnostic{SeverityWarning, kind, r.ref, nil, fmt.Sprintf(format, args...)}
+		if related != nil {
+			d.Related = &related.ref
+		}
+		return d, true
+	}
+
+	for j := 0; j < i; j++ {
+		if rules[j].sameRule(r) {
+			return warn(LintDuplicate, &rules[j], "repete %s", rules[j].ref)
+		}
+	}
+	if hasPriority {
+		return Diagnostic{}, false
+	}
+
+	// Uma regra posterior que aceita todos os paths desta sempre vence
+	for j := i + 1; j < len(rules); j++ {
+		later := &rules[j]
+		if later.sameRule(r) {
+			break // reportado como duplicado na regra posterior
+		}
+		if later.samePaths(r) && !r.negated && !later.negated {
+			return warn(LintConflictingType, later, "o tipo %s nunca é usado; %s redefine o pattern", r.tp.Type, later.ref)
+		}
+		if later.samePaths(r) || later.covers(r) {
+			if later.all && later.negated {
+				break // reportado como negação ampla na regra posterior
+			}
+			return warn(LintUnreachable, later, "nunca decide: %s vem depois e casa com todos os paths desta regra", later.ref)
+		}
+	}
+
+	if r.negated {
+		// No gitignore um arquivo não volta se um diretório acima dele foi excluído
+		if m.gitignore && r.prefix != "" {
+			if a := m.ancestorMatch(r.prefix); a >= 0 {
+				return warn(LintUnreachable, &rules[a], "nunca reinclui: o diretório %s já é excluído por %s", r.prefix, rules[a].ref)
+			}
+		}
+
+		overlaps := false
+		for j := 0; j < i && !overlaps; j++ {
+			overlaps = !rules[j].negated && rules[j].overlaps(r)
+		}
+		if !overlaps {
+			return warn(LintUnreachable, nil, "a negação nunca exclui nada: nenhuma regra anterior casa com os mesmos paths")
+		}
+
+		if r.all && !r.content {
+			return warn(LintBroadNegation, nil, "a negação casa com qualquer path; as regras anteriores nunca decidem")
+		}
+	}
+	return Diagnostic{}, false
+}
+
+// newLintRule calcula a forma da regra do mesmo jeito que compilePatterns a
+// interpreta. Retorna false para linhas que não viram regra.
+func newLintRule(tp TypedPattern, opts *MatcherOptions) (lintRule, bool) {
+	lr := lintRule{tp: tp, content: hasContentPredicates(tp)}
+	lr.ref = RuleRef{Pattern: tp.Pattern, Type: tp.Type, Source: tp.Source, Line: tp.Line}
+
+	prepare := func(p string) string {
+		if opts.NormalizeUnicode {
+			p = normalizeUnicode(p)
+		}
+		if !opts.CaseSensitive {
+			p = foldCase(p)
+		}
+		return p
+	}
+
+	if expr, negated, ok := regexPattern(tp); ok {
+		lr.negated, lr.key = negated, regexPatternPrefix+expr
+		if !opts.CaseSensitive {
+			expr = "(?i)" + expr
+		}
+		prefix := regexPrefix(prepare(expr), !opts.CaseSensitive)
+		lr.prefix = prefix[:strings.LastIndexByte(prefix, '/')+1]
+	} else if opts.Gitignore {
+		line := tp
+		line.Pattern = prepare(line.Pattern)
+		gr, ok := parseGitignorePattern(line)
+		if !ok {
+			return lintRule{}, false
+		}
+		lr.setGitignoreShape(gr)
+	} else {
+		p, negated := tp.Pattern, tp.IsNegated
+		if strings.HasPrefix(p, "!") {
+			p, negated = p[1:], true
+		}
+		if p == "" {
+			return lintRule{}, false
+		}
+		lr.negated = negated
+		lr.setShape(prepare(p), opts.MatchBasenameOnly)
+	}
+	lr.ref.Negated = lr.negated
+	return lr, true
+}
+
+// globMeta são os caracteres especiais dos globs fora do modo gitignore
+const globMeta = "*?[]{}\\"
+
+func (lr *lintRule) setShape(p string, basenameOnly bool) {
+	lr.key = p
+	lr.suffix = literalSuffix(p, globMeta)
+
+	// Com "/" o pattern só casa com o path completo, a não ser os paths exatos,
+	// que com MatchBasenameOnly também casam pelo basename
+	// ("dir/**" também casa com o próprio dir)
+	if strings.Contains(p, "/") && (strings.ContainsAny(p, globMeta) || !basenameOnly) {
+		lr.prefix = literalDir(strings.TrimSuffix(p, "/**"), globMeta)
+	}
+
+	switch {
+	case strings.Trim(p, "*") == "":
+		lr.all = true
+	case p[0] == '*' && !strings.ContainsAny(p[1:], globMeta+"/"):
+		lr.endsWith = p[1:]
+	case strings.HasSuffix(p, "/*") && !strings.ContainsAny(p[:len(p)-2], globMeta):
+		lr.under = p[:len(p)-1]
+	case strings.HasSuffix(p, "/**") && !strings.ContainsAny(p[:len(p)-3], globMeta):
+		lr.under = p[:len(p)-2]
+	}
+}
+
+// gitignoreMeta são os caracteres especiais do wildmatch
+const gitignoreMeta = "*?[\\"
+
+func (lr *lintRule) setGitignoreShape(gr gitignoreRule) {
+	p := gr.pattern
+	lr.key, lr.negated, lr.dirOnly = p, gr.negated, gr.dirOnly
+	if gr.dirOnly {
+		lr.key += "/"
+	}
+	if !gr.noDir {
+		lr.key = "/" + lr.key
+		lr.prefix = literalDir(p, gitignoreMeta)
+	}
+	lr.suffix = literalSuffix(p, gitignoreMeta)
+
+	if gr.dirOnly {
+		return
+	}
+	switch {
+	case gr.noDir && strings.Trim(p, "*") == "":
+		lr.all = true
+	case gr.noDir && gr.endsWith && len(p) > 1:
+		lr.endsWith = p[1:]
+	case !gr.noDir && strings.HasSuffix(p, "/**") && !strings.ContainsAny(p[:len(p)-3], gitignoreMeta):
+		lr.under = p[:len(p)-2]
+	}
+}
+
+// literalSuffix é o trecho literal no fim do basename do pattern
+func literalSuffix(p, meta string) string {
+	tail := p[strings.LastIndexAny(p, meta)+1:]
+	return tail[strings.LastIndexByte(tail, '/')+1:]
+}
+
+// literalDir é o diretório literal no início do pattern ("" se não houver)
+func literalDir(p, meta string) string {
+	lead := p
+	if i := strings.IndexAny(p, meta); i >= 0 {
+		lead = p[:i]
+	}
+	return lead[:strings.LastIndexByte(lead, '/')+1]
+}
+
+// samePaths diz se as duas regras aceitam exatamente os mesmos paths
+func (lr *lintRule) samePaths(o *lintRule) bool {
+	a, b := lr.tp, o.tp
+	return lr.key == o.key && lr.content == o.content &&
+		a.Shebang == b.Shebang && a.Magic == b.Magic && a.FirstLine == b.FirstLine &&
+		a.MinSize == b.MinSize && a.MaxSize == b.MaxSize &&
+		a.Binary == b.Binary && a.Executable == b.Executable && a.Generated == b.Generated
+}
+
+// sameRule diz se as duas regras são intercambiáveis
+func (lr *lintRule) sameRule(o *lintRule) bool {
+	return lr.samePaths(o) && lr.negated == o.negated && lr.tp.Type == o.tp.Type && lr.tp.Priority == o.tp.Priority
+}
+
+// covers diz se lr casa com todo path que o casa; regras de conteúdo e só de
+// diretório não garantem nada
+func (lr *lintRule) covers(o *lintRule) bool {
+	if lr.content || lr.dirOnly {
+		return false
+	}
+	switch {
+	case lr.all:
+		return true
+	case lr.endsWith != "":
+		return strings.HasSuffix(o.suffix, lr.endsWith)
+	case lr.under != "":
+		return strings.HasPrefix(o.prefix, lr.under)
+	}
+	return false
+}
+
+// overlaps diz se as regras podem casar com um mesmo path; só responde false
+// quando os literais do início ou do fim são incompatíveis
+func (lr *lintRule) overlaps(o *lintRule) bool {
+	if lr.suffix != "" && o.suffix != "" && !strings.HasSuffix(lr.suffix, o.suffix) && !strings.HasSuffix(o.suffix, lr.suffix) {
+		return false
+	}
+	if lr.prefix != "" && o.prefix != "" && !strings.HasPrefix(lr.prefix, o.prefix) && !strings.HasPrefix(o.prefix, lr.prefix) {
+		return false
+	}
+	return true
+}
+
+// hasLintErrors diz se algum diagnóstico impede o uso dos patterns
+func hasLintErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// runLint imprime os diagnósticos; usado pelo comando "patterns lint"
+func runLint(patterns []TypedPattern, opts *MatcherOptions, w io.Writer) bool {
+	diags := LintPatterns(patterns, opts)
+	for _, d := range diags {
+		icon := "⚠️ "
+		if d.Severity == SeverityError {
+			icon = "❌"
+		}
+		fmt.Fprintf(w, "%s %s\n", icon, d)
+	}
+	if len(diags) == 0 {
+		fmt.Fprintf(w, "✅ %d patterns sem problemas\n", len(patterns))
+	}
+	return !hasLintErrors(diags)
+}
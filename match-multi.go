@@ -0,0 +1,155 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MultiMatchResult contém todos os tipos que casaram com um path
+type MultiMatchResult struct {
+	Matched bool
+	Primary string   // tipo da regra de maior Priority (empate: a última)
+	Types   []string // todos os tipos, do primário para o de menor precedência
+}
+
+// MatchAll retorna todos os tipos cujos patterns casam com o path. Cada tipo é
+// avaliado como uma lista própria de regras que compartilha as negações: um
+// "!pattern" cancela as regras anteriores de todos os tipos, e um tipo casa se
+// a sua última regra que casa vem depois da última negação que casa.
+func (m *UltraFastMatcher) MatchAll(path string) MultiMatchResult {
+	return m.matchAll(m.preparePath(path))
+}
+
+func (m *UltraFastMatcher) matchAll(path string) MultiMatchResult {
+	return m.rankWinners(m.typeWinners(path, nil))
+}
+
+// rankWinners ordena os tipos pela precedência de outranks
+func (m *UltraFastMatcher) rankWinners(winners map[string]typeWinner) MultiMatchResult {
+	if len(winners) == 0 {
+		return MultiMatchResult{}
+	}
+
+	ranked := make([]typeWinner, 0, len(winners))
+	for _, w := range winners {
+		ranked = append(ranked, w)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return m.outranks(ranked[i], ranked[j])
+	})
+
+	result := MultiMatchResult{Matched: true, Primary: m.rules[ranked[0].rule].ptype}
+	for _, w := range ranked {
+		result.Types = append(result.Types, m.rules[w.rule].ptype)
+	}
+	return result
+}
+
+// typeWinner é a regra que decidiu um tipo e o nível do path onde isso ocorreu
+// (no gitignore, um diretório ancestral decide antes dos níveis abaixo dele)
+type typeWinner struct {
+	rule  int
+	depth int
+}
+
+// outranks define a precedência entre tipos: maior Priority, depois o nível
+// mais raso e, por fim, a regra que aparece por último
+func (m *UltraFastMatcher) outranks(a, b typeWinner) bool {
+	if pa, pb := m.rules[a.rule].priority, m.rules[b.rule].priority; pa != pb {
+		return pa > pb
+	}
+	if a.depth != b.depth {
+		return a.depth < b.depth
+	}
+	return a.rule > b.rule
+}
+
+// typeWinners retorna, para cada tipo que casa, a regra que o decidiu. extra
+// são regras que casam com o próprio path além das encontradas nos tiers
+// (regras de conteúdo já verificadas).
+func (m *UltraFastMatcher) typeWinners(path string, extra []int) map[string]typeWinner {
+	winners := make(map[string]typeWinner)
+	if !m.gitignore {
+		m.addTypeWinners(path, false, 0, extra, winners)
+		return winners
+	}
+
+	// No gitignore um diretório que casa vale para todo o conteúdo
+	isDir := strings.HasSuffix(path, "/")
+	path = strings.TrimRight(path, "/")
+	if path == "" {
+		return winners
+	}
+	depth := 0
+	for i := 1; i < len(path); i++ {
+		if path[i] == '/' {
+			m.addTypeWinners(path[:i], true, depth, nil, winners)
+			depth++
+		}
+	}
+	m.addTypeWinners(path, isDir, depth, extra, winners)
+	return winners
+}
+
+// addTypeWinners registra os tipos cuja última regra vem depois da última
+// negação que casa neste nível. Tipos já decididos em um diretório ancestral
+// são mantidos.
+func (m *UltraFastMatcher) addTypeWinners(path string, isDir bool, depth int, extra []int, winners map[string]typeWinner) {
+	matched := append([]int(nil), extra...)
+	m.eachMatch(path, isDir, func(rule int, _ matchTier) {
+		matched = append(matched, rule)
+	})
+
+	lastNegated := -1
+	for _, rule := range matched {
+		if m.rules[rule].negated && rule > lastNegated {
+			lastNegated = rule
+		}
+	}
+
+	level := make(map[string]int)
+	for _, rule := range matched {
+		if rule <= lastNegated || m.rules[rule].negated {
+			continue
+		}
+		ptype := m.rules[rule].ptype
+		if current, ok := level[ptype]; !ok || rule > current {
+			level[ptype] = rule
+		}
+	}
+	for ptype, rule := range level {
+		if _, ok := winners[ptype]; !ok {
+			winners[ptype] = typeWinner{rule, depth}
+		}
+	}
+}
+
+// eachMatch visita todas as regras, positivas ou negadas, que casam com o path,
+// junto com o tier onde casaram. Uma mesma regra pode ser visitada mais de uma
+// vez (path exato e basename).
+func (m *UltraFastMatcher) eachMatch(path string, isDir bool, visit func(rule int, tier matchTier)) {
+	each := func(rules []int, tier matchTier) {
+		for _, rule := range rules {
+			if m.applies(rule, isDir) {
+				visit(rule, tier)
+			}
+		}
+	}
+
+	each(m.exactPaths[path], tierExactPath)
+	basename := filepath.Base(path)
+	each(m.exactBasenames[basename], tierBasename)
+	for i := 0; i < len(basename); i++ {
+		if basename[i] == '.' {
+			each(m.extensions[basename[i:]], extensionTier(basename[i:]))
+		}
+	}
+	m.eachPrefix(path, isDir, visit)
+	m.eachSuffix(path, isDir, visit)
+	for _, tg := range m.compiledGlobs {
+		if tg.matches(path, basename) && m.applies(tg.rule, isDir) {
+			visit(tg.rule, m.rules[tg.rule].tier)
+		}
+	}
+}
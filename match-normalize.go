@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizePath coloca o path na forma canônica usada pelos patterns:
+// "\" vira "/", segmentos vazios e "." somem ("./src//a.go" -> "src/a.go") e a
+// "/" final é removida. Com keepDirSlash a "/" final é mantida, porque no
+// modo gitignore ela indica diretório. ".." não é resolvido.
+func normalizePath(path string, keepDirSlash bool) string {
+	if isNormalPath(path, keepDirSlash) {
+		return path
+	}
+
+	path = strings.ReplaceAll(path, "\\", "/")
+	absolute := strings.HasPrefix(path, "/")
+	dir := strings.HasSuffix(path, "/")
+
+	segments := strings.Split(path, "/")
+	kept := segments[:0]
+	for _, s := range segments {
+		if s != "" && s != "." {
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+
+	path = strings.Join(kept, "/")
+	if absolute {
+		path = "/" + path
+	}
+	if dir && keepDirSlash {
+		path += "/"
+	}
+	return path
+}
+
+// isNormalPath evita alocação no caso comum de paths já limpos
+func isNormalPath(path string, keepDirSlash bool) bool {
+	if strings.HasSuffix(path, "/") && !keepDirSlash {
+		return false
+	}
+	if strings.IndexByte(path, '\\') >= 0 || strings.Contains(path, "//") {
+		return false
+	}
+	return path != "." && !strings.HasPrefix(path, "./") &&
+		!strings.HasSuffix(path, "/.") && !strings.Contains(path, "/./")
+}
+
+// normalizeUnicode converte para NFC, a forma usada pelo Linux e pelo Git;
+// o macOS entrega nomes em NFD ("o" + acento combinado em vez de "ó")
+func normalizeUnicode(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return norm.NFC.String(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Formato do arquivo de patterns:
+//
+//	# comentário
+//	[Code]
+//	*.go
+//	!vendor/*
+//	%include comum.patterns
+//	%preset go vcs-ignore
+//
+//	[Doc priority=10]
+//	*.md
+//
+//	[Script]
+//	* @shebang=python
+//	* @firstline=^(from|import)\s
+//	*.bin @magic=7f454c46
+//
+//	[Asset]
+//	* @size>1MB
+//	!* @binary
+//
+// Cada seção [Tipo] define o Type dos patterns seguintes e, opcionalmente, a
+// Priority usada para escolher o tipo primário; [Code/Test] é um subtipo de
+// Code (ver TypeUnder). Linhas com "!" são negações, valem para todos os
+// tipos e podem aparecer antes de qualquer seção. Um pattern que seja só uma
+// classe de caracteres ("[abc]") precisa ser escrito como "\[abc]" para não
+// ser lido como seção; o parser remove esse "\" inicial, então o nome literal
+// "[abc]" fica "\\[abc]". Includes são relativos ao arquivo que os contém.
+// "%preset go docs" insere os presets embutidos naquela posição, como um
+// include: as regras seguintes têm precedência sobre eles.
+//
+// Atributos "@chave=valor" no fim da linha, separados por espaço, adicionam
+// predicados de conteúdo: shebang (interpretador), magic (bytes iniciais em
+// hexadecimal) e firstline (regexp da primeira linha, sem espaços; use \s).
+// Predicados de atributos: @size>N, @size>=N, @size<N e @size<=N (N em bytes
+// ou com sufixo KB, MB, GB; o máximo aceito é de pelo menos 1 byte), @binary,
+// @executable e @generated.
+
+// LoadPatternFile lê um arquivo de patterns tipados, resolvendo os %include
+func LoadPatternFile(path string) ([]TypedPattern, error) {
+	l := &patternLoader{readFile: os.ReadFile, visiting: make(map[string]bool)}
+	return l.load(path)
+}
+
+// ParsePatterns interpreta o conteúdo de um arquivo de patterns; source é
+// usado nas mensagens de erro, no TypedPattern.Source e para resolver includes
+func ParsePatterns(data []byte, source string) ([]TypedPattern, error) {
+	l := &patternLoader{readFile: os.ReadFile, visiting: map[string]bool{source: true}}
+	return l.parse(data, source)
+}
+
+type patternLoader struct {
+	readFile func(name string) ([]byte, error)
+	visiting map[string]bool // arquivos na pilha de includes, para detectar ciclos
+
+	// Se não for nil, recebe a versão de cada arquivo aberto, lida antes do
+	// conteúdo (ver WatchPatternFile)
+	stamps map[string]fileStamp
+}
+
+func (l *patternLoader) load(path string) ([]TypedPattern, error) {
+	if l.visiting[path] {
+		return nil, fmt.Errorf("include cycle at %s", path)
+	}
+	l.visiting[path] = true
+	defer delete(l.visiting, path)
+
+	if l.stamps != nil {
+		l.stamps[path] = stampFile(path)
+	}
+	data, err := l.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return l.parse(data, path)
+}
+
+func (l *patternLoader) parse(data []byte, source string) ([]TypedPattern, error) {
+	var patterns []TypedPattern
+	section, priority := "", 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			var err error
+			section, priority, err = parseSection(trimmed[1 : len(trimmed)-1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", source, lineNo, err)
+			}
+
+		case strings.HasPrefix(trimmed, "%"):
+			directive, arg, _ := strings.Cut(trimmed[1:], " ")
+			arg = strings.TrimSpace(arg)
+			if directive == "preset" && arg != "" {
+				presets, err := ExpandPresets(strings.Fields(arg))
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %w", source, lineNo, err)
+				}
+				patterns = append(patterns, presets...)
+				continue
+			}
+			if directive != "include" || arg == "" {
+				return nil, fmt.Errorf("%s:%d: invalid directive %q", source, lineNo, trimmed)
+			}
+			if !filepath.IsAbs(arg) {
+				arg = filepath.Join(filepath.Dir(source), arg)
+			}
+			included, err := l.load(arg)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", source, lineNo, err)
+			}
+			patterns = append(patterns, included...)
+
+		default:
+			tp := TypedPattern{Source: source, Line: lineNo}
+			pattern, err := parseAttributes(line, &tp)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", source, lineNo, err)
+			}
+
+			// Espaços finais escapados com "\" fazem parte do pattern
+			pattern = strings.TrimLeft(trimTrailingSpaces(pattern), " \t")
+			// "\[abc]" é a classe "[abc]", escrita assim para não virar seção, e
+			// "\\[abc]" é o nome literal, que no glob fica "\[abc]"
+			if strings.HasPrefix(pattern, "\\[") || strings.HasPrefix(pattern, "\\\\[") {
+				pattern = pattern[1:]
+			}
+			tp.Pattern = pattern
+			if strings.HasPrefix(pattern, "!") {
+				tp.IsNegated = true
+			} else if section == "" {
+				return nil, fmt.Errorf("%s:%d: pattern %q outside of a [Type] section", source, lineNo, pattern)
+			} else {
+				tp.Type, tp.Priority = section, priority
+			}
+			patterns = append(patterns, tp)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return patterns, nil
+}
+
+// parseSection interpreta o conteúdo de "[Tipo priority=N]" ou
+// "[Tipo/Subtipo priority=N]"
+func parseSection(header string) (string, int, error) {
+	fields := strings.Fields(header)
+	if len(fields) == 0 {
+		return "", 0, fmt.Errorf("empty section name")
+	}
+
+	priority := 0
+	for _, field := range fields[1:] {
+		key, value, _ := strings.Cut(field, "=")
+		if key != "priority" {
+			return "", 0, fmt.Errorf("unknown section option %q", field)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid priority %q", value)
+		}
+		priority = n
+	}
+	// "Code/Test" é um subtipo de Code (ver TypeUnder)
+	if err := validType(fields[0]); err != nil {
+		return "", 0, err
+	}
+	return fields[0], priority, nil
+}
+
+// parseAttributes remove os atributos "@chave=valor" do fim da linha e os
+// aplica em tp
+func parseAttributes(line string, tp *TypedPattern) (string, error) {
+	start := -1
+	for i := 1; i < len(line); i++ {
+		if line[i] == '@' && (line[i-1] == ' ' || line[i-1] == '\t') {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return line, nil
+	}
+
+	for _, attr := range strings.Fields(line[start:]) {
+		if !strings.HasPrefix(attr, "@") {
+			return "", fmt.Errorf("invalid pattern attribute %q", attr)
+		}
+		switch name := attr[1:]; name {
+		case "binary":
+			tp.Binary = true
+			continue
+		case "executable":
+			tp.Executable = true
+			continue
+		case "generated":
+			tp.Generated = true
+			continue
+		}
+		if strings.HasPrefix(attr, "@size") {
+			if err := parseSizeAttribute(attr[len("@size"):], tp); err != nil {
+				return "", fmt.Errorf("invalid pattern attribute %q: %w", attr, err)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(attr[1:], "=")
+		if !ok || value == "" {
+			return "", fmt.Errorf("invalid pattern attribute %q", attr)
+		}
+		switch key {
+		case "shebang":
+			tp.Shebang = value
+		case "magic":
+			magic, err := hex.DecodeString(value)
+			if err != nil {
+				return "", fmt.Errorf("invalid magic %q: %w", value, err)
+			}
+			tp.Magic = string(magic)
+		case "firstline":
+			tp.FirstLine = value
+		default:
+			return "", fmt.Errorf("unknown pattern attribute %q", attr)
+		}
+	}
+	return line[:start], nil
+}
+
+// parseSizeAttribute interpreta ">1MB", ">=1MB", "<10KB" ou "<=10KB"
+func parseSizeAttribute(expr string, tp *TypedPattern) error {
+	op := strings.TrimRight(expr[:min(2, len(expr))], "0123456789")
+	n, err := parseSize(expr[len(op):])
+	if err != nil {
+		return err
+	}
+	switch op {
+	case ">":
+		if n == math.MaxInt64 {
+			return fmt.Errorf("size %q is too large", expr[len(op):])
+		}
+		tp.MinSize = n + 1
+	case ">=":
+		tp.MinSize = n
+	case "<":
+		tp.MaxSize = n - 1
+	case "<=":
+		tp.MaxSize = n
+	default:
+		return fmt.Errorf("unknown operator %q", op)
+	}
+	// MaxSize 0 significa "sem limite": "@size<1" viraria uma regra só de path
+	if strings.HasPrefix(op, "<") && tp.MaxSize <= 0 {
+		return fmt.Errorf("maximum size must be at least 1 byte")
+	}
+	return nil
+}
+
+// parseSize lê um tamanho em bytes com sufixo opcional KB, MB ou GB
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		scale  int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+	digits, scale := s, int64(1)
+	upper := strings.ToUpper(s)
+	for _, u := range units {
+		if strings.HasSuffix(upper, u.suffix) {
+			digits, scale = s[:len(s)-len(u.suffix)], u.scale
+			break
+		}
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", digits)
+	}
+	if n > math.MaxInt64/scale {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return n * scale, nil
+}
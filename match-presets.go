@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// presetOrder é a precedência dos presets: como a última regra que casa
+// vence, um preset sobrescreve os anteriores (requirements.txt é Code e não
+// Doc, angular vem depois de node, vcs-ignore por último). Os patterns do
+// projeto vêm depois de todos.
+var presetOrder = []string{"docs", "go", "python", "node", "angular", "terraform", "vcs-ignore"}
+
+// presetSources usa o formato dos arquivos de patterns
+var presetSources = map[string]string{
+	"go": `
+[Code]
+*.go
+go.mod
+go.sum
+go.work
+
+[Code/Test]
+*_test.go
+
+!vendor/*
+`,
+	"python": `
+[Code]
+*.py
+*.pyi
+pyproject.toml
+setup.py
+setup.cfg
+requirements.txt
+
+[Code/Test]
+test_*.py
+*_test.py
+conftest.py
+
+!*.pyc
+!__pycache__/*
+!.venv/*
+!venv/*
+!.pytest_cache/*
+!.mypy_cache/*
+`,
+	"node": `
+[Code]
+*.js
+*.mjs
+*.cjs
+*.jsx
+*.ts
+*.tsx
+package.json
+tsconfig.json
+
+[Code/Test]
+*.test.js
+*.test.ts
+*.spec.js
+*.spec.ts
+
+!node_modules/*
+!dist/*
+!coverage/*
+!*.min.js
+!package-lock.json
+`,
+	"angular": `
+[Code]
+*.html
+*.css
+*.scss
+angular.json
+
+!.angular/*
+`,
+	"terraform": `
+[Code]
+*.tf
+*.tfvars
+*.hcl
+
+!.terraform/*
+!*.tfstate
+!*.tfstate.backup
+!.terraform.lock.hcl
+`,
+	"docs": `
+[Doc]
+*.md
+*.mdx
+*.rst
+*.adoc
+*.txt
+docs/*
+LICENSE
+`,
+	"vcs-ignore": `
+!.git/*
+!.hg/*
+!.svn/*
+!.bzr/*
+`,
+}
+
+// PresetNames lista os presets na ordem de precedência
+func PresetNames() []string {
+	return slices.Clone(presetOrder)
+}
+
+// ExpandPresets retorna os patterns dos presets na ordem de precedência,
+// qualquer que seja a ordem dos nomes. O Source de cada pattern é
+// "preset:<nome>", então Explain mostra de qual preset a regra veio.
+func ExpandPresets(names []string) ([]TypedPattern, error) {
+	for _, name := range names {
+		if _, ok := presetSources[name]; !ok {
+			return nil, fmt.Errorf("unknown preset %q", name)
+		}
+	}
+
+	var patterns []TypedPattern
+	for _, name := range presetOrder {
+		if !slices.Contains(names, name) {
+			continue
+		}
+		preset, err := ParsePatterns([]byte(presetSources[name]), "preset:"+name)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, preset...)
+	}
+	return patterns, nil
+}
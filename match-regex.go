@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// Patterns com o prefixo "re:" (ou "!re:") são expressões regulares RE2
+// testadas contra o path completo: "re:^services/[^/]+/migrations/\d+_.*\.sql$".
+// Sem "^" e "$" a regexp casa com qualquer trecho do path.
+const regexPatternPrefix = "re:"
+
+// regexGlob adapta uma regexp para a interface glob.Glob
+type regexGlob struct {
+	*regexp.Regexp
+	prefix string // prefixo literal que todo path aceito precisa ter
+}
+
+func (g regexGlob) Match(s string) bool {
+	return g.MatchString(s)
+}
+
+// regexPattern separa a negação e o prefixo "re:" de um pattern
+func regexPattern(tp TypedPattern) (expr string, negated bool, ok bool) {
+	pattern, negated := tp.Pattern, tp.IsNegated
+	if strings.HasPrefix(pattern, "!") {
+		pattern, negated = pattern[1:], true
+	}
+	if !strings.HasPrefix(pattern, regexPatternPrefix) {
+		return "", false, false
+	}
+	return pattern[len(regexPatternPrefix):], negated, true
+}
+
+// compileRegexRule registra uma regra "re:", igual nos dois modos
+func (m *UltraFastMatcher) compileRegexRule(tp TypedPattern, expr string, negated bool, opts *MatcherOptions) error {
+	rule := newMatchRule(len(m.rules), tp, negated)
+	m.addRule(rule)
+
+	if opts.NormalizeUnicode {
+		expr = normalizeUnicode(expr)
+	}
+	if !opts.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	rg, err := newRegexGlob(expr, !opts.CaseSensitive)
+	if err != nil {
+		return err
+	}
+
+	if hasContentPredicates(tp) {
+		return m.addContentRule(typedGlob{rg, rule.index, globRegex, expr}, tp)
+	}
+	m.addRegex(rg, expr, rule.index)
+	return nil
+}
+
+// newRegexGlob compila a regexp; fold diz se os paths passam por foldCase
+func newRegexGlob(expr string, fold bool) (regexGlob, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return regexGlob{}, fmt.Errorf("invalid regexp %q: %w", expr, err)
+	}
+	return regexGlob{re, regexPrefix(expr, fold)}, nil
+}
+
+// addRegex indexa a regexp pelos diretórios do seu prefixo literal; a trie só
+// filtra os candidatos e a regexp confirma. Sem um diretório literal no
+// início ela vai para o tier linear de globs.
+func (m *UltraFastMatcher) addRegex(rg regexGlob, expr string, rule int) {
+	m.rules[rule].tier = tierRegex
+	if i := strings.LastIndexByte(rg.prefix, '/'); i > 0 {
+		m.prefixes.insert(strings.Split(rg.prefix[:i], "/"), trieRule{rule: rule, re: rg.Regexp})
+		return
+	}
+	m.compiledGlobs = append(m.compiledGlobs, typedGlob{rg, rule, globRegex, expr})
+}
+
+// regexPrefix extrai o prefixo literal de uma regexp ancorada com "^".
+// Literais com (?i) são dobrados como os paths quando fold (ver foldCase);
+// sem fold o path mantém a caixa, então o prefixo para no primeiro deles.
+func regexPrefix(expr string, fold bool) string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil || re.Op != syntax.OpConcat || len(re.Sub) < 2 || re.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, sub := range re.Sub[1:] {
+		if sub.Op != syntax.OpLiteral {
+			break
+		}
+		s := string(sub.Rune)
+		if sub.Flags&syntax.FoldCase != 0 {
+			if !fold {
+				break
+			}
+			s = foldCase(s)
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gobwas/glob"
+)
+
+// Formato do snapshot:
+//
+//	"UFMS" | versão (uint32 big endian) | sha256 do payload | payload gob
+//
+// O payload guarda regras, mapas e tries como estão no matcher compilado. Os
+// globs não são serializáveis e são recompilados a partir do pattern já
+// normalizado que gerou cada um.
+
+const (
+	snapshotMagic   = "UFMS"
+	snapshotVersion = 7 // 2: regras de conteúdo; 3: predicados de atributos; 4: regexps; 5: expansão de {} e []; 6: "/" como separador dos globs; 7: prefixo de regexps com (?i)
+)
+
+// snapshotData é o payload; os campos são exportados para o encoding/gob
+type snapshotData struct {
+	Digest           string
+	Gitignore        bool
+	CaseFold         bool
+	NormalizePaths   bool
+	NormalizeUnicode bool
+
+	Rules          []snapshotRule
+	ExactPaths     map[string][]int
+	ExactBasenames map[string][]int
+	Extensions     map[string][]int
+	Prefixes       []snapshotTrieRule
+	Suffixes       []snapshotTrieRule
+	Globs          []snapshotGlob
+	Content        []snapshotContent
+	Expanded       int
+}
+
+type snapshotRule struct {
+	Pattern  string
+	Type     string
+	Negated  bool
+	DirOnly  bool
+	Priority int
+	Tier     int
+	Source   string
+	Line     int
+}
+
+type snapshotTrieRule struct {
+	Segments      []string
+	Rule          int
+	SingleSegment bool
+	Regex         string
+}
+
+type snapshotGlob struct {
+	Pattern string
+	Rule    int
+	Target  int
+}
+
+type snapshotContent struct {
+	Glob      snapshotGlob
+	Shebang   string
+	Magic     string
+	FirstLine string
+
+	MinSize    int64
+	MaxSize    int64
+	Binary     bool
+	Executable bool
+	Generated  bool
+}
+
+// WriteSnapshot grava o matcher compilado em w. O cache não é incluído.
+func (m *UltraFastMatcher) WriteSnapshot(w io.Writer) error {
+	data := snapshotData{
+		Digest:           m.digest,
+		Gitignore:        m.gitignore,
+		CaseFold:         m.caseFold,
+		NormalizePaths:   m.normalizePaths,
+		NormalizeUnicode: m.normalizeUnicode,
+		ExactPaths:       m.exactPaths,
+		ExactBasenames:   m.exactBasenames,
+		Extensions:       m.extensions,
+		Prefixes:         m.prefixes.entries(),
+		Suffixes:         m.suffixes.entries(),
+		Expanded:         m.expandedCount,
+	}
+	for _, rule := range m.rules {
+		data.Rules = append(data.Rules, snapshotRule{
+			Pattern:  rule.pattern,
+			Type:     rule.ptype,
+			Negated:  rule.negated,
+			DirOnly:  rule.dirOnly,
+			Priority: rule.priority,
+			Tier:     int(rule.tier),
+			Source:   rule.source,
+			Line:     rule.line,
+		})
+	}
+	for _, tg := range m.compiledGlobs {
+		data.Globs = append(data.Globs, snapshotGlob{tg.pattern, tg.rule, int(tg.target)})
+	}
+	for _, cr := range m.contentRules {
+		sc := snapshotContent{
+			Glob:       snapshotGlob{cr.path.pattern, cr.path.rule, int(cr.path.target)},
+			Shebang:    cr.pred.shebang,
+			Magic:      cr.pred.magic,
+			MinSize:    cr.pred.minSize,
+			MaxSize:    cr.pred.maxSize,
+			Binary:     cr.pred.binary,
+			Executable: cr.pred.executable,
+			Generated:  cr.pred.generated,
+		}
+		if cr.pred.firstLine != nil {
+			sc.FirstLine = cr.pred.firstLine.String()
+		}
+		data.Content = append(data.Content, sc)
+	}
+
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(data); err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	sum := sha256.Sum256(payload.Bytes())
+
+	header := make([]byte, 0, len(snapshotMagic)+4+len(sum))
+	header = append(header, snapshotMagic...)
+	header = binary.BigEndian.AppendUint32(header, snapshotVersion)
+	header = append(header, sum[:]...)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload.Bytes())
+	return err
+}
+
+// ReadSnapshot reconstrói um matcher gravado por WriteSnapshot. As opções que
+// afetam a compilação (Gitignore, CaseSensitive, MatchBasenameOnly,
+// Normalize*) vêm do snapshot; de opts são usados só o cache e os workers.
+func ReadSnapshot(r io.Reader, opts *MatcherOptions) (*UltraFastMatcher, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := len(snapshotMagic) + 4 + sha256.Size
+	if len(raw) < headerSize || string(raw[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, fmt.Errorf("not a matcher snapshot")
+	}
+	if version := binary.BigEndian.Uint32(raw[len(snapshotMagic):]); version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	payload := raw[headerSize:]
+	if sum := sha256.Sum256(payload); !bytes.Equal(sum[:], raw[len(snapshotMagic)+4:headerSize]) {
+		return nil, fmt.Errorf("snapshot checksum mismatch")
+	}
+
+	var data snapshotData
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return data.matcher(opts)
+}
+
+func (data *snapshotData) matcher(opts *MatcherOptions) (*UltraFastMatcher, error) {
+	if err := data.checkRules(); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = defaultMatcherOptions()
+	}
+	o := *opts
+	o.Gitignore = data.Gitignore
+	o.CaseSensitive = !data.CaseFold
+	o.NormalizePaths = data.NormalizePaths
+	o.NormalizeUnicode = data.NormalizeUnicode
+	m := newMatcher(&o)
+	m.digest = data.Digest
+
+	for i, sr := range data.Rules {
+		m.addRule(matchRule{
+			index:    i,
+			pattern:  sr.Pattern,
+			ptype:    sr.Type,
+			negated:  sr.Negated,
+			dirOnly:  sr.DirOnly,
+			priority: sr.Priority,
+			tier:     matchTier(sr.Tier),
+			source:   sr.Source,
+			line:     sr.Line,
+		})
+	}
+
+	m.expandedCount = data.Expanded
+
+	// gob decodifica mapas vazios como nil
+	if data.ExactPaths != nil {
+		m.exactPaths = data.ExactPaths
+	}
+	if data.ExactBasenames != nil {
+		m.exactBasenames = data.ExactBasenames
+	}
+	if data.Extensions != nil {
+		m.extensions = data.Extensions
+	}
+	for _, tr := range data.Prefixes {
+		rule := trieRule{rule: tr.Rule, singleSegment: tr.SingleSegment}
+		if tr.Regex != "" {
+			rg, err := newRegexGlob(tr.Regex, data.CaseFold)
+			if err != nil {
+				return nil, err
+			}
+			rule.re = rg.Regexp
+		}
+		m.prefixes.insert(tr.Segments, rule)
+	}
+	for _, tr := range data.Suffixes {
+		m.suffixes.insert(tr.Segments, trieRule{rule: tr.Rule})
+	}
+
+	for _, sg := range data.Globs {
+		tg, err := data.typedGlob(sg, compileGlob)
+		if err != nil {
+			return nil, err
+		}
+		m.compiledGlobs = append(m.compiledGlobs, tg)
+	}
+	for _, sc := range data.Content {
+		tg, err := data.typedGlob(sc.Glob, compileContentGlob)
+		if err != nil {
+			return nil, err
+		}
+		tp := TypedPattern{
+			Shebang:    sc.Shebang,
+			Magic:      sc.Magic,
+			FirstLine:  sc.FirstLine,
+			MinSize:    sc.MinSize,
+			MaxSize:    sc.MaxSize,
+			Binary:     sc.Binary,
+			Executable: sc.Executable,
+			Generated:  sc.Generated,
+		}
+		if err := m.addContentRule(tg, tp); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// typedGlob recompila o glob no modo em que o snapshot foi gravado; compile é
+// o mesmo usado por compilePatterns para o tipo de regra
+func (data *snapshotData) typedGlob(sg snapshotGlob, compile func(string) (glob.Glob, error)) (typedGlob, error) {
+	if globTarget(sg.Target) == globRegex {
+		rg, err := newRegexGlob(sg.Pattern, data.CaseFold)
+		if err != nil {
+			return typedGlob{}, err
+		}
+		return typedGlob{rg, sg.Rule, globRegex, sg.Pattern}, nil
+	}
+	if data.Gitignore {
+		gr := &gitignoreRule{noDir: globTarget(sg.Target) == globBase}
+		gr.setPattern(sg.Pattern)
+		return typedGlob{gr, sg.Rule, globTarget(sg.Target), sg.Pattern}, nil
+	}
+	g, err := compile(sg.Pattern)
+	if err != nil {
+		return typedGlob{}, err
+	}
+	return typedGlob{g, sg.Rule, globTarget(sg.Target), sg.Pattern}, nil
+}
+
+// checkRules garante que os tiers só referenciam regras existentes
+func (data *snapshotData) checkRules() error {
+	valid := func(rule int) bool { return rule >= 0 && rule < len(data.Rules) }
+	for _, tier := range []map[string][]int{data.ExactPaths, data.ExactBasenames, data.Extensions} {
+		for _, rules := range tier {
+			for _, rule := range rules {
+				if !valid(rule) {
+					return fmt.Errorf("invalid snapshot rule %d", rule)
+				}
+			}
+		}
+	}
+	for _, trie := range [][]snapshotTrieRule{data.Prefixes, data.Suffixes} {
+		for _, tr := range trie {
+			if !valid(tr.Rule) {
+				return fmt.Errorf("invalid snapshot rule %d", tr.Rule)
+			}
+		}
+	}
+	globs := append([]snapshotGlob(nil), data.Globs...)
+	for _, sc := range data.Content {
+		globs = append(globs, sc.Glob)
+	}
+	for _, sg := range globs {
+		if !valid(sg.Rule) || sg.Pattern == "" {
+			return fmt.Errorf("invalid snapshot glob %q", sg.Pattern)
+		}
+	}
+	return nil
+}
+
+// entries lista as regras da trie com os segmentos de cada nó, preservando a
+// ordem das regras dentro do nó
+func (t *segmentTrie) entries() []snapshotTrieRule {
+	var out []snapshotTrieRule
+	var walk func(n *segmentNode, segments []string)
+	walk = func(n *segmentNode, segments []string) {
+		for _, tr := range n.rules {
+			str := snapshotTrieRule{append([]string(nil), segments...), tr.rule, tr.singleSegment, ""}
+			if tr.re != nil {
+				str.Regex = tr.re.String()
+			}
+			out = append(out, str)
+		}
+		for s, child := range n.children {
+			walk(child, append(segments, s))
+		}
+	}
+	walk(&t.root, nil)
+	return out
+}
+
+// SaveSnapshotFile grava o snapshot em path. O arquivo é escrito ao lado e
+// renomeado, então quem lê em paralelo vê o snapshot antigo ou o novo inteiro.
+func (m *UltraFastMatcher) SaveSnapshotFile(path string) error {
+	var buf bytes.Buffer
+	if err := m.WriteSnapshot(&buf); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // não faz nada depois do Rename
+	_, err = f.Write(buf.Bytes())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o644)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadSnapshotFile lê um snapshot gravado por SaveSnapshotFile
+func LoadSnapshotFile(path string, opts *MatcherOptions) (*UltraFastMatcher, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSnapshot(f, opts)
+}
+
+// patternsDigest identifica um conjunto de patterns e as opções que afetam a
+// compilação; um snapshot com o mesmo digest pode substituir a compilação
+func patternsDigest(patterns []TypedPattern, opts *MatcherOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d %t %t %t %t %t\n", snapshotVersion, opts.Gitignore, opts.CaseSensitive,
+		opts.MatchBasenameOnly, opts.NormalizePaths, opts.NormalizeUnicode)
+	presets, _ := ExpandPresets(opts.Presets)
+	for _, tp := range append(presets, patterns...) {
+		fmt.Fprintf(h, "%q %q %t %d %q %d %q %q %q %d %d %t %t %t\n", tp.Pattern, tp.Type, tp.IsNegated, tp.Priority, tp.Source, tp.Line,
+			tp.Shebang, tp.Magic, tp.FirstLine, tp.MinSize, tp.MaxSize, tp.Binary, tp.Executable, tp.Generated)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ErrSnapshotNotSaved indica que CachedMatcher compilou os patterns mas não
+// conseguiu gravar o snapshot
+var ErrSnapshotNotSaved = errors.New("snapshot not saved")
+
+// CachedMatcher usa o snapshot em path quando ele foi gravado a partir dos
+// mesmos patterns e opções; senão compila e regrava o snapshot. Um snapshot
+// ilegível é tratado como ausente. Se só a gravação falhar (um cache somente
+// leitura, por exemplo), retorna o matcher compilado junto com um erro
+// ErrSnapshotNotSaved, que serve de aviso.
+func CachedMatcher(path string, patterns []TypedPattern, opts *MatcherOptions) (*UltraFastMatcher, error) {
+	if opts == nil {
+		opts = defaultMatcherOptions()
+	}
+	digest := patternsDigest(patterns, opts)
+	if m, err := LoadSnapshotFile(path, opts); err == nil && m.digest == digest {
+		return m, nil
+	}
+
+	m, err := NewUltraFastMatcher(patterns, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.SaveSnapshotFile(path); err != nil {
+		return m, fmt.Errorf("%w: %w", ErrSnapshotNotSaved, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// segmentTrie indexa regras por segmentos de path. Prefixos ("src/app/") são
+// inseridos na ordem natural e sufixos ("/test/data") na ordem inversa, então
+// o lookup custa O(profundidade do path) independente do número de regras.
+type segmentTrie struct {
+	root segmentNode
+	size int
+}
+
+type segmentNode struct {
+	children map[string]*segmentNode
+	rules    []trieRule // em ordem crescente de regra
+}
+
+type trieRule struct {
+	rule          int
+	singleSegment bool           // gitignore "dir/*": só um nível abaixo do prefixo
+	re            *regexp.Regexp // regexp "re:" que ainda precisa casar com o path
+}
+
+func (t *segmentTrie) insert(segments []string, tr trieRule) {
+	n := &t.root
+	for _, s := range segments {
+		if n.children == nil {
+			n.children = make(map[string]*segmentNode)
+		}
+		child, ok := n.children[s]
+		if !ok {
+			child = &segmentNode{}
+			n.children[s] = child
+		}
+		n = child
+	}
+	n.rules = append(n.rules, tr)
+	t.size++
+}
+
+// addPrefix indexa um prefixo terminado em "/" ("src/" casa com "src/...")
+func (m *UltraFastMatcher) addPrefix(prefix string, rule int, singleSegment bool) {
+	segments := strings.Split(strings.TrimSuffix(prefix, "/"), "/")
+	m.prefixes.insert(segments, trieRule{rule: rule, singleSegment: singleSegment})
+	m.rules[rule].tier = tierPrefix
+}
+
+// addSuffix indexa um sufixo iniciado por "/" ("/test" casa com ".../test")
+func (m *UltraFastMatcher) addSuffix(suffix string, rule int) {
+	segments := strings.Split(strings.TrimPrefix(suffix, "/"), "/")
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+	m.suffixes.insert(segments, trieRule{rule: rule})
+	m.rules[rule].tier = tierSuffix
+}
+
+// lastPrefix desce a trie pelos diretórios do path e retorna a última regra
+// posterior a best cujo prefixo casa
+func (m *UltraFastMatcher) lastPrefix(path string, best int, isDir bool) int {
+	n := &m.prefixes.root
+	start := 0
+	for {
+		// Um prefixo precisa de "/" depois do segmento
+		end := strings.IndexByte(path[start:], '/')
+		if end < 0 {
+			return best
+		}
+		if n = n.children[path[start:start+end]]; n == nil {
+			return best
+		}
+		start += end + 1
+
+		rest := path[start:]
+		for i := len(n.rules) - 1; i >= 0 && n.rules[i].rule > best; i-- {
+			tr := n.rules[i]
+			if !tr.matches(path, rest) {
+				continue
+			}
+			if m.applies(tr.rule, isDir) {
+				best = tr.rule
+				break
+			}
+		}
+	}
+}
+
+// lastSuffix sobe a trie invertida a partir do basename e retorna a última
+// regra posterior a best cujo sufixo casa
+func (m *UltraFastMatcher) lastSuffix(path string, best int, isDir bool) int {
+	n := &m.suffixes.root
+	end := len(path)
+	for {
+		// Um sufixo precisa de "/" antes do segmento
+		start := strings.LastIndexByte(path[:end], '/')
+		if start < 0 {
+			return best
+		}
+		if n = n.children[path[start+1:end]]; n == nil {
+			return best
+		}
+		end = start
+
+		for i := len(n.rules) - 1; i >= 0 && n.rules[i].rule > best; i-- {
+			if m.applies(n.rules[i].rule, isDir) {
+				best = n.rules[i].rule
+				break
+			}
+		}
+	}
+}
+
+// matches aplica as restrições da regra além do prefixo; rest é o path
+// depois dele
+func (tr trieRule) matches(path, rest string) bool {
+	if tr.singleSegment && (rest == "" || strings.Contains(rest, "/")) {
+		return false
+	}
+	return tr.re == nil || tr.re.MatchString(path)
+}
+
+// tier é onde a regra casou: uma regra expandida pode estar em vários tiers
+func (tr trieRule) tier() matchTier {
+	if tr.re != nil {
+		return tierRegex
+	}
+	return tierPrefix
+}
+
+// eachPrefix visita todas as regras de prefixo que casam com o path
+func (m *UltraFastMatcher) eachPrefix(path string, isDir bool, visit func(rule int, tier matchTier)) {
+	n := &m.prefixes.root
+	start := 0
+	for {
+		end := strings.IndexByte(path[start:], '/')
+		if end < 0 {
+			return
+		}
+		if n = n.children[path[start:start+end]]; n == nil {
+			return
+		}
+		start += end + 1
+
+		rest := path[start:]
+		for _, tr := range n.rules {
+			if tr.matches(path, rest) && m.applies(tr.rule, isDir) {
+				visit(tr.rule, tr.tier())
+			}
+		}
+	}
+}
+
+// eachSuffix visita todas as regras de sufixo que casam com o path
+func (m *UltraFastMatcher) eachSuffix(path string, isDir bool, visit func(rule int, tier matchTier)) {
+	n := &m.suffixes.root
+	end := len(path)
+	for {
+		start := strings.LastIndexByte(path[:end], '/')
+		if start < 0 {
+			return
+		}
+		if n = n.children[path[start+1:end]]; n == nil {
+			return
+		}
+		end = start
+
+		for _, tr := range n.rules {
+			if m.applies(tr.rule, isDir) {
+				visit(tr.rule, tierSuffix)
+			}
+		}
+	}
+}
+
+// eachRule visita as regras do nó e de todos os seus descendentes
+func (n *segmentNode) eachRule(visit func(tr trieRule)) {
+	for _, tr := range n.rules {
+		visit(tr)
+	}
+	for _, child := range n.children {
+		child.eachRule(visit)
+	}
+}
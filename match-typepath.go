@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Tipos formam uma árvore pelo separador "/": "Code/Test" e "Code/Generated"
+// são filhos de "Code". A regra que casa continua definindo o tipo inteiro;
+// a hierarquia só muda consultas (TypeUnder) e contagens (TypeCounts).
+
+// typeSeparator separa os níveis de um tipo
+const typeSeparator = "/"
+
+// TypePath retorna os níveis do tipo ("Code/Test" -> ["Code", "Test"]); nil
+// se o path não casou
+func (r MatchResult) TypePath() []string {
+	if !r.Matched || r.Type == "" {
+		return nil
+	}
+	return strings.Split(r.Type, typeSeparator)
+}
+
+// IsA diz se o resultado casou com parent ou com algum tipo abaixo dele
+func (r MatchResult) IsA(parent string) bool {
+	return r.Matched && TypeUnder(r.Type, parent)
+}
+
+// TypeUnder diz se ptype é parent ou um descendente dele ("Code/Test" está
+// abaixo de "Code", mas "CodeGen" não)
+func TypeUnder(ptype, parent string) bool {
+	return ptype == parent || strings.HasPrefix(ptype, parent+typeSeparator)
+}
+
+// TypeAncestors retorna ptype e os tipos acima dele, da raiz para baixo
+// ("Code/Test" -> ["Code", "Code/Test"])
+func TypeAncestors(ptype string) []string {
+	var types []string
+	for i := 0; i < len(ptype); i++ {
+		if strings.HasPrefix(ptype[i:], typeSeparator) {
+			types = append(types, ptype[:i])
+		}
+	}
+	return append(types, ptype)
+}
+
+// validType rejeita tipos com níveis vazios ("Code/", "/Test", "Code//Test")
+func validType(ptype string) error {
+	if slices.Contains(strings.Split(ptype, typeSeparator), "") {
+		return fmt.Errorf("invalid type %q", ptype)
+	}
+	return nil
+}
+
+// TypeCounts conta itens por tipo somando também nos tipos pais: um arquivo
+// "Code/Test" conta em "Code/Test" e em "Code"
+type TypeCounts map[string]int
+
+// Add conta um item com os tipos dados; cada tipo e seus ancestrais recebem
+// no máximo uma unidade, então "Code" e "Code/Test" juntos contam um só
+// "Code" (ver MatchAll)
+func (c TypeCounts) Add(types ...string) {
+	var seen []string
+	for _, ptype := range types {
+		for _, t := range TypeAncestors(ptype) {
+			if !slices.Contains(seen, t) {
+				seen = append(seen, t)
+				c[t]++
+			}
+		}
+	}
+}
+
+// Types lista os tipos contados em ordem de árvore: cada pai antes dos filhos
+func (c TypeCounts) Types() []string {
+	types := make([]string, 0, len(c))
+	for t := range c {
+		types = append(types, t)
+	}
+	slices.SortFunc(types, func(a, b string) int {
+		return slices.Compare(strings.Split(a, typeSeparator), strings.Split(b, typeSeparator))
+	})
+	return types
+}
+
+// writeTypeCounts imprime a árvore de contagens indentada por nível
+func writeTypeCounts(w io.Writer, counts TypeCounts, unit string) {
+	for _, ptype := range counts.Types() {
+		path := strings.Split(ptype, typeSeparator)
+		indent := strings.Repeat("  ", len(path))
+		fmt.Fprintf(w, "%s%s: %d %s\n", indent, path[len(path)-1], counts[ptype], unit)
+	}
+}
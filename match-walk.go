@@ -0,0 +1,268 @@
+package main
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// DirState resume como os patterns tratam todo o conteúdo de um diretório
+type DirState int
+
+const (
+	DirMixed    DirState = iota // arquivos podem ter resultados diferentes
+	DirExcluded                 // nenhum arquivo abaixo casa
+	DirIncluded                 // todos os arquivos abaixo casam com o mesmo tipo
+)
+
+func (s DirState) String() string {
+	return [...]string{"mixed", "excluded", "included"}[s]
+}
+
+// DirResult é o resultado de MatchDir; Type só é preenchido em DirIncluded
+type DirResult struct {
+	State DirState
+	Type  string
+}
+
+// ruleReach diz se uma regra pode casar com algum path abaixo de um diretório
+// e se casa com todos eles
+type ruleReach struct {
+	may    bool
+	covers bool
+}
+
+// MatchDir informa se o conteúdo do diretório está todo excluído, todo
+// incluído com um tipo conhecido ou misto. A análise é conservadora: na dúvida
+// o resultado é DirMixed, e o walker volta a chamar Match para cada arquivo.
+// "" e "." representam a raiz.
+func (m *UltraFastMatcher) MatchDir(dir string) DirResult {
+	dir = strings.TrimRight(m.preparePath(dir), "/")
+	if dir == "." {
+		dir = ""
+	}
+	d := ""
+	if dir != "" {
+		d = dir + "/"
+	}
+
+	// No gitignore um diretório que casa decide todo o conteúdo
+	ancestorTypes := []string(nil)
+	if m.gitignore && d != "" {
+		if m.hasPriority {
+			ancestorTypes = m.matchAll(d).Types
+		} else if r := m.matchGitignore(d); r.Matched {
+			return DirResult{DirIncluded, r.Type}
+		}
+	}
+
+	reach := m.subtreeReach(d)
+
+	// Fora do gitignore uma negação que cobre o diretório cancela tudo antes dela
+	lastNeg := -1
+	if !m.gitignore {
+		for r := len(m.rules) - 1; r >= 0; r-- {
+			if reach[r].covers && m.rules[r].negated {
+				lastNeg = r
+				break
+			}
+		}
+	}
+
+	cover, anyPositive := -1, false
+	for r := lastNeg + 1; r < len(m.rules); r++ {
+		rule := m.rules[r]
+		if !reach[r].may || rule.negated {
+			continue
+		}
+		anyPositive = true
+		if reach[r].covers && !rule.dirOnly {
+			cover = r
+		}
+	}
+
+	switch {
+	case len(ancestorTypes) > 1:
+		return DirResult{State: DirMixed}
+	case len(ancestorTypes) == 1:
+		// Negações abaixo não desfazem o ancestral; só outro tipo muda o primário
+		for r, rule := range m.rules {
+			if reach[r].may && !rule.negated && rule.ptype != ancestorTypes[0] {
+				return DirResult{State: DirMixed}
+			}
+		}
+		return DirResult{DirIncluded, ancestorTypes[0]}
+	case !anyPositive:
+		return DirResult{State: DirExcluded}
+	case cover >= 0 && m.onlyType(reach, lastNeg, cover, m.rules[cover].ptype):
+		return DirResult{DirIncluded, m.rules[cover].ptype}
+	}
+	return DirResult{State: DirMixed}
+}
+
+// onlyType verifica que nenhuma regra alcançável depois de cover muda o
+// resultado: negações cancelariam o tipo e outros tipos o substituiriam. Com
+// prioridades qualquer outro tipo alcançável pode virar o primário.
+func (m *UltraFastMatcher) onlyType(reach []ruleReach, lastNeg, cover int, ptype string) bool {
+	for r := lastNeg + 1; r < len(m.rules); r++ {
+		rule := m.rules[r]
+		if !reach[r].may || r == cover {
+			continue
+		}
+		if r > cover && rule.negated {
+			return false
+		}
+		if !rule.negated && rule.ptype != ptype && (r > cover || m.hasPriority) {
+			return false
+		}
+	}
+	return true
+}
+
+// subtreeReach avalia, para cada regra, se ela alcança os paths abaixo do
+// prefixo d ("" = todos)
+func (m *UltraFastMatcher) subtreeReach(d string) []ruleReach {
+	reach := make([]ruleReach, len(m.rules))
+	mark := func(rule int, covers bool) {
+		reach[rule].may = true
+		reach[rule].covers = reach[rule].covers || covers
+	}
+	markAll := func(rules []int) {
+		for _, rule := range rules {
+			mark(rule, false)
+		}
+	}
+
+	for path, rules := range m.exactPaths {
+		if strings.HasPrefix(path, d) {
+			markAll(rules)
+		}
+	}
+	// Basenames, extensões e sufixos casam em qualquer diretório
+	for _, rules := range m.exactBasenames {
+		markAll(rules)
+	}
+	for _, rules := range m.extensions {
+		markAll(rules)
+	}
+	m.suffixes.root.eachRule(func(tr trieRule) {
+		mark(tr.rule, false)
+	})
+	m.prefixReach(d, mark)
+	for _, tg := range m.compiledGlobs {
+		if may, covers := tg.reach(d); may {
+			mark(tg.rule, covers)
+		}
+	}
+	// Regras de conteúdo nunca cobrem: dependem de cada arquivo
+	for _, cr := range m.contentRules {
+		if may, _ := cr.path.reach(d); may {
+			mark(cr.path.rule, false)
+		}
+	}
+	return reach
+}
+
+// prefixReach desce a trie pelos segmentos de d: prefixos no caminho cobrem o
+// diretório inteiro, os que ficam abaixo dele casam só com parte do conteúdo
+func (m *UltraFastMatcher) prefixReach(d string, mark func(rule int, covers bool)) {
+	n := &m.prefixes.root
+	if d != "" {
+		segments := strings.Split(strings.TrimSuffix(d, "/"), "/")
+		for i, s := range segments {
+			if n = n.children[s]; n == nil {
+				return
+			}
+			for _, tr := range n.rules {
+				// "dir/*" cobre só quando dir é o próprio diretório: casa com
+				// cada filho, e no gitignore o filho decide o que está abaixo
+				switch {
+				case tr.re != nil:
+					mark(tr.rule, false)
+				case !tr.singleSegment || i == len(segments)-1:
+					mark(tr.rule, true)
+				}
+			}
+		}
+	}
+	for _, child := range n.children {
+		child.eachRule(func(tr trieRule) {
+			mark(tr.rule, false)
+		})
+	}
+}
+
+// reach compara a parte literal inicial do glob com o prefixo d
+func (tg typedGlob) reach(d string) (may, covers bool) {
+	if rg, ok := tg.glob.(regexGlob); ok {
+		return strings.HasPrefix(d, rg.prefix) || strings.HasPrefix(rg.prefix, d), false
+	}
+	literal := tg.pattern[:simpleLength(tg.pattern)]
+	_, gitignore := tg.glob.(*gitignoreRule)
+	if !gitignore {
+		if i := strings.IndexAny(tg.pattern, globMeta); i >= 0 {
+			literal = tg.pattern[:i]
+		}
+	}
+	// Com "/" como separador só "**" cobre a subárvore inteira
+	rest := tg.pattern[len(literal):]
+	subtree := !gitignore && rest == "**"
+
+	switch {
+	case tg.target == globBase, tg.target == globPathOrBase && !strings.Contains(tg.pattern, "/"):
+		// O basename nunca contém "/"
+		return true, literal == "" && (rest == "*" || rest == "**")
+	case strings.HasPrefix(d, literal):
+		return true, subtree
+	}
+	return strings.HasPrefix(literal, d), false
+}
+
+// WalkMatches percorre fsys a partir de root e chama fn para cada arquivo que
+// casa. Diretórios DirExcluded não são lidos, e nos DirIncluded os arquivos
+// recebem o tipo do diretório sem passar por Match. Com regras de conteúdo os
+// demais arquivos passam por MatchFile. Como em MatchFile, arquivos sem
+// extensão têm o shebang lido para preencher Language.
+func (m *UltraFastMatcher) WalkMatches(fsys fs.FS, root string, fn func(path string, result MatchResult) error) error {
+	included, prefix, ptype := false, "", ""
+
+	return fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if included && !strings.HasPrefix(path, prefix) {
+			included = false
+		}
+
+		if d.IsDir() {
+			if included {
+				return nil
+			}
+			switch r := m.MatchDir(path); r.State {
+			case DirExcluded:
+				return fs.SkipDir
+			case DirIncluded:
+				included, prefix, ptype = true, path+"/", r.Type
+				if path == "." {
+					prefix = ""
+				}
+			}
+			return nil
+		}
+
+		result := MatchResult{Matched: true, Type: ptype, Language: DetectLanguage(path)}
+		switch {
+		case included:
+			result = withInterpreter(&fileProbe{fsys: fsys, name: path}, result)
+		case len(m.contentRules) > 0:
+			if result, err = m.MatchFile(fsys, path); err != nil {
+				return err
+			}
+		default:
+			result = withInterpreter(&fileProbe{fsys: fsys, name: path}, m.Match(path))
+		}
+		if result.Matched {
+			return fn(path, result)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// fileStamp identifica uma versão de arquivo sem ler o conteúdo
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// WatchPatternFile compila o arquivo de patterns e, até ctx ser cancelado,
+// verifica a cada interval se ele ou algum dos seus %include mudou. A cada
+// mudança chama reload com o matcher recompilado; se a leitura ou a compilação
+// falhar, reload recebe o erro e quem chamou continua com o matcher anterior.
+func WatchPatternFile(ctx context.Context, path string, interval time.Duration, opts *MatcherOptions, reload func(*UltraFastMatcher, error)) (*UltraFastMatcher, error) {
+	m, stamps, err := compilePatternFile(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if !changed(stamps) {
+				continue
+			}
+			// Com erro, tenta de novo só quando algum arquivo mudar outra vez
+			next, current, err := compilePatternFile(path, opts)
+			stamps = current
+			reload(next, err)
+		}
+	}()
+	return m, nil
+}
+
+// compilePatternFile carrega e compila o arquivo, guardando a versão de cada
+// arquivo aberto (o principal e os incluídos, mesmo os que ainda não têm
+// patterns). As versões são lidas antes do conteúdo: uma edição durante a
+// leitura aparece como mudança no próximo ciclo.
+func compilePatternFile(path string, opts *MatcherOptions) (*UltraFastMatcher, map[string]fileStamp, error) {
+	l := &patternLoader{readFile: os.ReadFile, visiting: make(map[string]bool), stamps: make(map[string]fileStamp)}
+	patterns, err := l.load(path)
+	if err != nil {
+		return nil, l.stamps, err
+	}
+
+	m, err := NewUltraFastMatcher(patterns, opts)
+	if err != nil {
+		return nil, l.stamps, err
+	}
+	return m, l.stamps, nil
+}
+
+// stampFile lê a versão atual do arquivo; arquivos ausentes ficam com a
+// versão zero
+func stampFile(name string) fileStamp {
+	if info, err := os.Stat(name); err == nil {
+		return fileStamp{info.ModTime(), info.Size()}
+	}
+	return fileStamp{}
+}
+
+// currentStamps lê a versão atual dos arquivos
+func currentStamps(files map[string]fileStamp) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for name := range files {
+		stamps[name] = stampFile(name)
+	}
+	return stamps
+}
+
+func changed(stamps map[string]fileStamp) bool {
+	for name, stamp := range currentStamps(stamps) {
+		if stamp != stamps[name] {
+			return true
+		}
+	}
+	return false
+}